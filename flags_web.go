@@ -7,16 +7,14 @@ import (
 	"golang.org/x/text/language"
 )
 
-func parseFlags() *game.Game {
-	var forceLanguage *language.Tag
+func parseFlags() *options {
+	o := &options{}
 	locale := game.DefaultLocale()
 	if locale != "" {
 		tag, err := language.Parse(locale)
 		if err == nil {
-			forceLanguage = &tag
+			o.locale = &tag
 		}
 	}
-	game.LoadLocale(forceLanguage)
-
-	return game.NewGame()
+	return o
 }
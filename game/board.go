@@ -8,9 +8,12 @@ import (
 	"log"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"code.rocket9labs.com/tslocum/bgammon"
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
+	"code.rocket9labs.com/tslocum/boxcars/game/match"
 	"code.rocket9labs.com/tslocum/etk"
 	"code.rocketnine.space/tslocum/messeji"
 	"github.com/hajimehoshi/ebiten/v2"
@@ -57,7 +60,7 @@ type board struct {
 
 	debug int // Print and draw debug information
 
-	Client *Client
+	Client *client.Client
 
 	dragX, dragY int
 
@@ -79,6 +82,7 @@ type board struct {
 
 	timerLabel     *etk.Text
 	clockLabel     *etk.Text
+	latencyLabel   *etk.Text
 	showMenuButton *etk.Button
 
 	menuGrid *etk.Grid
@@ -87,6 +91,15 @@ type board struct {
 	highlightCheckbox    *etk.Checkbox
 	settingsGrid         *etk.Grid
 
+	autoSaveCheckbox *etk.Checkbox
+	exportGrid       *etk.Grid
+
+	positionLabel     *etk.Text
+	positionIDInput   *etk.Input
+	matchIDInput      *etk.Input
+	positionInfo      *etk.Text
+	enterPositionGrid *etk.Grid
+
 	matchStatusGrid *etk.Grid
 
 	inputGrid          *etk.Grid
@@ -98,6 +111,8 @@ type board struct {
 
 	bearOffOverlay *etk.Button
 
+	connectionOverlay *etk.Button
+
 	leaveGameGrid         *etk.Grid
 	confirmLeaveGameFrame *etk.Frame
 
@@ -114,6 +129,12 @@ type board struct {
 
 	availableMoves [][]int
 
+	history     *match.History
+	viewEntry   *match.HistoryEntry // Entry of the position shown, or nil when following the game.
+	showHistory bool
+	historyList *historyList
+	historyGrid *etk.Grid
+
 	widget *BoardWidget
 
 	repositionLock *sync.Mutex
@@ -155,7 +176,9 @@ func NewBoard() *board {
 		buttonsUndoOKGrid:       etk.NewGrid(),
 		menuGrid:                etk.NewGrid(),
 		settingsGrid:            etk.NewGrid(),
+		exportGrid:              etk.NewGrid(),
 		uiGrid:                  etk.NewGrid(),
+		historyGrid:             etk.NewGrid(),
 		frame:                   etk.NewFrame(),
 		confirmLeaveGameFrame:   etk.NewFrame(),
 		chatGrid:                etk.NewGrid(),
@@ -183,6 +206,9 @@ func NewBoard() *board {
 	})
 	b.bearOffOverlay.SetVisible(false)
 
+	b.connectionOverlay = etk.NewButton(gotext.Get("Connecting..."), b.selectRetry)
+	b.connectionOverlay.SetVisible(false)
+
 	b.recreateButtonGrid()
 
 	{
@@ -190,7 +216,19 @@ func NewBoard() *board {
 		b.menuGrid.AddChildAt(etk.NewBox(), 1, 0, 1, 1)
 		b.menuGrid.AddChildAt(etk.NewButton(gotext.Get("Settings"), b.showSettings), 2, 0, 1, 1)
 		b.menuGrid.AddChildAt(etk.NewBox(), 3, 0, 1, 1)
-		b.menuGrid.AddChildAt(etk.NewButton(gotext.Get("Leave"), b.leaveGame), 4, 0, 1, 1)
+		b.menuGrid.AddChildAt(etk.NewButton(gotext.Get("History"), b.toggleHistory), 4, 0, 1, 1)
+		b.menuGrid.AddChildAt(etk.NewBox(), 5, 0, 1, 1)
+		b.menuGrid.AddChildAt(etk.NewButton(gotext.Get("Export"), b.showExport), 6, 0, 1, 1)
+		b.menuGrid.AddChildAt(etk.NewBox(), 7, 0, 1, 1)
+		b.menuGrid.AddChildAt(etk.NewButton(gotext.Get("Leave"), b.leaveGame), 8, 0, 1, 1)
+
+		b.positionLabel = etk.NewText("")
+		b.positionLabel.SetBackgroundColor(color.RGBA{40, 24, 9, 255})
+		b.positionLabel.SetVertical(messeji.AlignCenter)
+		b.positionLabel.SetScrollBarVisible(false)
+		b.menuGrid.AddChildAt(b.positionLabel, 0, 2, 5, 1)
+		b.menuGrid.AddChildAt(etk.NewButton(gotext.Get("Copy"), b.copyPosition), 6, 2, 1, 1)
+		b.menuGrid.AddChildAt(etk.NewButton(gotext.Get("Enter position"), b.showEnterPosition), 8, 2, 1, 1)
 		b.menuGrid.SetVisible(false)
 	}
 
@@ -243,6 +281,73 @@ func NewBoard() *board {
 		b.settingsGrid.SetVisible(false)
 	}
 
+	{
+		exportLabel := etk.NewText(gotext.Get("Export match"))
+		exportLabel.SetHorizontal(messeji.AlignCenter)
+
+		formatGrid := etk.NewGrid()
+		formatGrid.SetColumnSizes(-1, 20, -1)
+		formatGrid.AddChildAt(etk.NewButton(gotext.Get("Jellyfish (.mat)"), b.exportMat), 0, 0, 1, 1)
+		formatGrid.AddChildAt(etk.NewButton(gotext.Get("SGF (.sgf)"), b.exportSGF), 2, 0, 1, 1)
+
+		b.autoSaveCheckbox = etk.NewCheckbox(b.toggleAutoSaveCheckbox)
+		b.autoSaveCheckbox.SetBorderColor(triangleA)
+		b.autoSaveCheckbox.SetCheckColor(triangleA)
+		b.autoSaveCheckbox.SetSelected(game != nil && game.config != nil && game.config.AutoSaveMatches)
+
+		autoSaveLabel := &ClickableText{
+			Text: etk.NewText(gotext.Get("Save matches when they end")),
+			onSelected: func() {
+				b.autoSaveCheckbox.SetSelected(!b.autoSaveCheckbox.Selected())
+				b.toggleAutoSaveCheckbox()
+			},
+		}
+		autoSaveLabel.SetVertical(messeji.AlignCenter)
+
+		checkboxGrid := etk.NewGrid()
+		checkboxGrid.AddChildAt(b.autoSaveCheckbox, 0, 0, 1, 1)
+		checkboxGrid.AddChildAt(autoSaveLabel, 1, 0, 4, 1)
+
+		b.exportGrid.SetBackground(color.RGBA{40, 24, 9, 255})
+		b.exportGrid.SetColumnSizes(20, -1, -1, 20)
+		b.exportGrid.AddChildAt(exportLabel, 1, 0, 2, 1)
+		b.exportGrid.AddChildAt(formatGrid, 1, 1, 2, 1)
+		b.exportGrid.AddChildAt(etk.NewBox(), 1, 2, 1, 1)
+		if ShowAutoSaveMatches {
+			b.exportGrid.SetRowSizes(72, 72, 20, 72, 20, -1)
+			b.exportGrid.AddChildAt(checkboxGrid, 1, 3, 2, 1)
+			b.exportGrid.AddChildAt(etk.NewBox(), 1, 4, 1, 1)
+			b.exportGrid.AddChildAt(etk.NewButton(gotext.Get("Return"), b.hideMenu), 0, 5, 4, 1)
+		} else {
+			b.exportGrid.SetRowSizes(72, 72, 20, -1)
+			b.exportGrid.AddChildAt(etk.NewButton(gotext.Get("Return"), b.hideMenu), 0, 3, 4, 1)
+		}
+		b.exportGrid.SetVisible(false)
+	}
+
+	{
+		enterPositionLabel := etk.NewText(gotext.Get("Enter position"))
+		enterPositionLabel.SetHorizontal(messeji.AlignCenter)
+
+		b.positionIDInput = etk.NewInput("", "", b.selectEnterPosition)
+		b.matchIDInput = etk.NewInput("", "", b.selectEnterPosition)
+		b.positionInfo = etk.NewText(gotext.Get("Enter a GNU Backgammon Position ID and, optionally, a Match ID."))
+
+		b.enterPositionGrid = etk.NewGrid()
+		b.enterPositionGrid.SetBackground(color.RGBA{40, 24, 9, 255})
+		b.enterPositionGrid.SetColumnSizes(20, 200, -1, -1, 20)
+		b.enterPositionGrid.SetRowSizes(72, 72, 20, 72, 20, 72, -1)
+		b.enterPositionGrid.AddChildAt(enterPositionLabel, 1, 0, 3, 1)
+		b.enterPositionGrid.AddChildAt(etk.NewText(gotext.Get("Position ID")), 1, 1, 1, 1)
+		b.enterPositionGrid.AddChildAt(b.positionIDInput, 2, 1, 2, 1)
+		b.enterPositionGrid.AddChildAt(etk.NewText(gotext.Get("Match ID")), 1, 3, 1, 1)
+		b.enterPositionGrid.AddChildAt(b.matchIDInput, 2, 3, 2, 1)
+		b.enterPositionGrid.AddChildAt(b.positionInfo, 1, 5, 3, 1)
+		b.enterPositionGrid.AddChildAt(etk.NewButton(gotext.Get("Cancel"), b.hideMenu), 0, 6, 3, 1)
+		b.enterPositionGrid.AddChildAt(etk.NewButton(gotext.Get("Show"), b.confirmEnterPosition), 3, 6, 2, 1)
+		b.enterPositionGrid.SetVisible(false)
+	}
+
 	{
 		leaveGameLabel := etk.NewText(gotext.Get("Leave match?"))
 		leaveGameLabel.SetHorizontal(messeji.AlignCenter)
@@ -274,22 +379,38 @@ func NewBoard() *board {
 	clockLabel.TextField.SetVertical(messeji.AlignCenter)
 	b.clockLabel = clockLabel
 
+	latencyLabel := etk.NewText("")
+	latencyLabel.SetForegroundColor(triangleA)
+	latencyLabel.SetScrollBarVisible(false)
+	latencyLabel.SetSingleLine(true)
+	latencyLabel.TextField.SetHorizontal(messeji.AlignCenter)
+	latencyLabel.TextField.SetVertical(messeji.AlignCenter)
+	b.latencyLabel = latencyLabel
+
 	b.showMenuButton = etk.NewButton("Menu", b.toggleMenu)
 
 	b.matchStatusGrid = etk.NewGrid()
 	b.matchStatusGrid.AddChildAt(b.timerLabel, 0, 0, 1, 1)
 	b.matchStatusGrid.AddChildAt(b.clockLabel, 1, 0, 1, 1)
+	b.matchStatusGrid.AddChildAt(b.latencyLabel, 2, 0, 1, 1)
 	if !AutoEnableTouchInput {
-		b.matchStatusGrid.AddChildAt(b.showMenuButton, 2, 0, 1, 1)
+		b.matchStatusGrid.AddChildAt(b.showMenuButton, 3, 0, 1, 1)
 	}
 
-	b.uiGrid.AddChildAt(b.matchStatusGrid, 0, 0, 1, 1)
-	b.uiGrid.AddChildAt(etk.NewBox(), 0, 1, 1, 1)
-	b.uiGrid.AddChildAt(statusBuffer, 0, 2, 1, 1)
-	b.uiGrid.AddChildAt(etk.NewBox(), 0, 3, 1, 1)
-	b.uiGrid.AddChildAt(gameBuffer, 0, 4, 1, 1)
-	b.uiGrid.AddChildAt(etk.NewBox(), 0, 5, 1, 1)
-	b.uiGrid.AddChildAt(b.inputGrid, 0, 6, 1, 1)
+	{
+		b.historyList = newHistoryList(b)
+
+		buttons := etk.NewGrid()
+		buttons.AddChildAt(etk.NewButton("|<", b.selectHistoryFirst), 0, 0, 1, 1)
+		buttons.AddChildAt(etk.NewButton("<", b.selectHistoryPrevious), 1, 0, 1, 1)
+		buttons.AddChildAt(etk.NewButton(">", b.selectHistoryNext), 2, 0, 1, 1)
+		buttons.AddChildAt(etk.NewButton(gotext.Get("Live"), b.selectHistoryLive), 3, 0, 1, 1)
+
+		b.historyGrid.AddChildAt(b.historyList, 0, 0, 1, 1)
+		b.historyGrid.AddChildAt(buttons, 0, 1, 1, 1)
+	}
+
+	b.recreateUIGrid()
 
 	b.frame.SetPositionChildren(true)
 
@@ -303,6 +424,7 @@ func NewBoard() *board {
 		f.AddChild(b.playerPipCount)
 		f.AddChild(b.uiGrid)
 		f.AddChild(b.bearOffOverlay)
+		f.AddChild(b.connectionOverlay)
 		b.frame.AddChild(f)
 	}
 
@@ -331,6 +453,8 @@ func NewBoard() *board {
 		f := etk.NewFrame()
 		f.AddChild(b.menuGrid)
 		f.AddChild(b.settingsGrid)
+		f.AddChild(b.exportGrid)
+		f.AddChild(b.enterPositionGrid)
 		f.AddChild(b.leaveGameGrid)
 		b.frame.AddChild(f)
 	}
@@ -377,6 +501,7 @@ func (b *board) fontUpdated() {
 
 	b.timerLabel.SetFont(b.fontFace, fontMutex)
 	b.clockLabel.SetFont(b.fontFace, fontMutex)
+	b.latencyLabel.SetFont(b.fontFace, fontMutex)
 
 	b.opponentPipCount.SetFont(bufferFont, fontMutex)
 	b.playerPipCount.SetFont(bufferFont, fontMutex)
@@ -410,6 +535,29 @@ func (b *board) recreateInputGrid() {
 	}
 }
 
+// recreateUIGrid arranges the match status, logs and input. The move history
+// is shown in place of the game log when enabled.
+func (b *board) recreateUIGrid() {
+	*b.uiGrid = *etk.NewGrid()
+
+	logBuffer := etk.Widget(gameBuffer)
+	if b.showHistory {
+		logBuffer = b.historyGrid
+	}
+	b.uiGrid.AddChildAt(b.matchStatusGrid, 0, 0, 1, 1)
+	b.uiGrid.AddChildAt(etk.NewBox(), 0, 1, 1, 1)
+	b.uiGrid.AddChildAt(statusBuffer, 0, 2, 1, 1)
+	b.uiGrid.AddChildAt(etk.NewBox(), 0, 3, 1, 1)
+	b.uiGrid.AddChildAt(logBuffer, 0, 4, 1, 1)
+	b.uiGrid.AddChildAt(etk.NewBox(), 0, 5, 1, 1)
+	b.uiGrid.AddChildAt(b.inputGrid, 0, 6, 1, 1)
+
+	// Update layout.
+	if game != nil {
+		game.forceLayout = true
+	}
+}
+
 func (b *board) showButtonGrid(buttonGrid *etk.Grid) {
 	b.buttonsOnlyRollGrid.SetVisible(false)
 	b.buttonsOnlyUndoGrid.SetVisible(false)
@@ -503,7 +651,11 @@ func (b *board) cancelLeaveGame() error {
 }
 
 func (b *board) confirmLeaveGame() error {
-	b.Client.Out <- []byte("leave")
+	if game.viewingMatch {
+		game.closeMatch()
+		return nil
+	}
+	b.Client.Leave()
 	return nil
 }
 
@@ -519,17 +671,28 @@ func (b *board) showSettings() error {
 	return nil
 }
 
+func (b *board) showExport() error {
+	b.menuGrid.SetVisible(false)
+	b.exportGrid.SetVisible(true)
+	return nil
+}
+
 func (b *board) hideMenu() error {
 	b.menuGrid.SetVisible(false)
 	b.settingsGrid.SetVisible(false)
+	b.exportGrid.SetVisible(false)
+	if b.enterPositionGrid.Visible() {
+		b.enterPositionGrid.SetVisible(false)
+		etk.SetFocus(inputBuffer)
+	}
 	return nil
 }
 
 func (b *board) toggleMenu() error {
 	if b.menuGrid.Visible() {
-		b.menuGrid.SetVisible(false)
-		b.settingsGrid.SetVisible(false)
+		b.hideMenu()
 	} else {
+		b.updatePositionLabel()
 		b.menuGrid.SetVisible(true)
 	}
 
@@ -557,13 +720,20 @@ func (b *board) toggleKeyboard() error {
 	return nil
 }
 
+func (b *board) selectRetry() error {
+	if b.Client != nil {
+		b.Client.Retry()
+	}
+	return nil
+}
+
 func (b *board) selectRoll() error {
-	b.Client.Out <- []byte("roll")
+	b.Client.Roll()
 	return nil
 }
 
 func (b *board) selectOK() error {
-	b.Client.Out <- []byte("ok")
+	b.Client.Submit()
 	return nil
 }
 
@@ -579,7 +749,7 @@ func (b *board) selectUndo() error {
 			return
 		}
 		lastMove := b.gameState.Moves[l-1]
-		b.Client.Out <- []byte(fmt.Sprintf("mv %d/%d", lastMove[1], lastMove[0]))
+		b.Client.Move(lastMove[1], lastMove[0])
 		b.movePiece(lastMove[1], lastMove[0])
 		b.gameState.Moves = b.gameState.Moves[:l-1]
 	}()
@@ -587,12 +757,12 @@ func (b *board) selectUndo() error {
 }
 
 func (b *board) selectDouble() error {
-	b.Client.Out <- []byte("double")
+	b.Client.Double()
 	return nil
 }
 
 func (b *board) selectResign() error {
-	b.Client.Out <- []byte("resign")
+	b.Client.Resign()
 	return nil
 }
 
@@ -608,6 +778,30 @@ func (b *board) toggleHighlightCheckbox() error {
 	return nil
 }
 
+func (b *board) toggleAutoSaveCheckbox() error {
+	if game.config == nil {
+		return nil
+	}
+	game.config.AutoSaveMatches = b.autoSaveCheckbox.Selected()
+	err := game.config.Save()
+	if err != nil {
+		log.Printf("error: failed to save configuration: %s", err)
+	}
+	return nil
+}
+
+func (b *board) exportMat() error {
+	b.hideMenu()
+	go b.exportMatch(match.FormatMat)
+	return nil
+}
+
+func (b *board) exportSGF() error {
+	b.hideMenu()
+	go b.exportMatch(match.FormatSGF)
+	return nil
+}
+
 func (b *board) newSprite(white bool) *Sprite {
 	s := &Sprite{}
 	s.colorWhite = white
@@ -926,11 +1120,13 @@ func (b *board) Draw(screen *ebiten.Image) {
 		}
 	}
 
+	state := b.displayState()
+
 	// Draw opponent dice
 
-	playerRoll := b.gameState.Roll1
-	opponentRoll := b.gameState.Roll2
-	if b.gameState.PlayerNumber == 2 {
+	playerRoll := state.Roll1
+	opponentRoll := state.Roll2
+	if state.PlayerNumber == 2 {
 		playerRoll, opponentRoll = opponentRoll, playerRoll
 	}
 
@@ -948,52 +1144,52 @@ func (b *board) Draw(screen *ebiten.Image) {
 		}
 	}
 
-	opponent := b.gameState.OpponentPlayer()
+	opponent := state.OpponentPlayer()
 	if opponent.Name != "" {
 		innerCenter := b.x + (b.w / 4) - int(b.barWidth/4) + int(b.horizontalBorderSize/2)
-		if b.gameState.Turn == 0 {
+		if state.Turn == 0 {
 			if opponentRoll != 0 {
 				op := &ebiten.DrawImageOptions{}
 				op.GeoM.Translate(float64(innerCenter-diceSize/2), float64(b.y+(b.innerH/2))-diceGap-float64(diceSize))
 				screen.DrawImage(diceImage(opponentRoll), op)
 			}
-		} else if b.gameState.Turn != b.gameState.PlayerNumber && b.gameState.Roll1 != 0 {
+		} else if state.Turn != state.PlayerNumber && state.Roll1 != 0 {
 			{
 				op := &ebiten.DrawImageOptions{}
 				op.GeoM.Translate(float64(innerCenter-diceSize)-diceGap, float64(b.y+(b.innerH/2))-diceGap-float64(diceSize))
-				screen.DrawImage(diceImage(b.gameState.Roll1), op)
+				screen.DrawImage(diceImage(state.Roll1), op)
 			}
 
 			{
 				op := &ebiten.DrawImageOptions{}
 				op.GeoM.Translate(float64(innerCenter)+diceGap, float64(b.y+(b.innerH/2))-diceGap-float64(diceSize))
-				screen.DrawImage(diceImage(b.gameState.Roll2), op)
+				screen.DrawImage(diceImage(state.Roll2), op)
 			}
 		}
 	}
 
 	// Draw player dice
 
-	player := b.gameState.LocalPlayer()
+	player := state.LocalPlayer()
 	if player.Name != "" {
 		innerCenter := b.x + b.w/2 + b.w/4 + int(b.barWidth/4) - int(b.horizontalBorderSize/2)
-		if b.gameState.Turn == 0 {
+		if state.Turn == 0 {
 			if playerRoll != 0 {
 				op := &ebiten.DrawImageOptions{}
 				op.GeoM.Translate(float64(innerCenter-diceSize/2), float64(b.y+(b.innerH/2))-diceGap-float64(diceSize))
 				screen.DrawImage(diceImage(playerRoll), op)
 			}
-		} else if b.gameState.Turn == b.gameState.PlayerNumber && b.gameState.Roll1 != 0 {
+		} else if state.Turn == state.PlayerNumber && state.Roll1 != 0 {
 			{
 				op := &ebiten.DrawImageOptions{}
 				op.GeoM.Translate(float64(innerCenter-diceSize)-diceGap, float64(b.y+(b.innerH/2))-diceGap-float64(diceSize))
-				screen.DrawImage(diceImage(b.gameState.Roll1), op)
+				screen.DrawImage(diceImage(state.Roll1), op)
 			}
 
 			{
 				op := &ebiten.DrawImageOptions{}
 				op.GeoM.Translate(float64(innerCenter)+diceGap, float64(b.y+(b.innerH/2))-diceGap-float64(diceSize))
-				screen.DrawImage(diceImage(b.gameState.Roll2), op)
+				screen.DrawImage(diceImage(state.Roll2), op)
 			}
 		}
 	}
@@ -1081,13 +1277,14 @@ func (b *board) setRect(x, y, w, h int) {
 		matchStatus = 44
 	}
 	b.uiGrid.SetRowSizes(matchStatus, int(b.horizontalBorderSize/2), -1, int(b.horizontalBorderSize/2), -1, int(b.horizontalBorderSize/2), int(inputAndButtons))
+	b.historyGrid.SetRowSizes(-1, game.scale(baseButtonHeight))
 
 	{
-		dialogWidth := game.scale(620)
+		dialogWidth := game.scale(780)
 		if dialogWidth > game.screenW {
 			dialogWidth = game.screenW
 		}
-		dialogHeight := game.scale(100)
+		dialogHeight := game.scale(210)
 		if dialogHeight > game.screenH {
 			dialogHeight = game.screenH
 		}
@@ -1108,6 +1305,21 @@ func (b *board) setRect(x, y, w, h int) {
 
 		x, y := game.screenW/2-dialogWidth/2, game.screenH/2-dialogHeight+int(b.verticalBorderSize)
 		b.settingsGrid.SetRect(image.Rect(x, y, x+dialogWidth, y+dialogHeight))
+		b.exportGrid.SetRect(image.Rect(x, y, x+dialogWidth, y+dialogHeight))
+	}
+
+	{
+		dialogWidth := game.scale(780)
+		if dialogWidth > game.screenW {
+			dialogWidth = game.screenW
+		}
+		dialogHeight := 72 + 72 + 20 + 72 + 20 + 72 + game.scale(baseButtonHeight)
+		if dialogHeight > game.screenH {
+			dialogHeight = game.screenH
+		}
+
+		x, y := game.screenW/2-dialogWidth/2, game.screenH/2-dialogHeight+int(b.verticalBorderSize)
+		b.enterPositionGrid.SetRect(image.Rect(x, y, x+dialogWidth, y+dialogHeight))
 	}
 
 	{
@@ -1124,12 +1336,24 @@ func (b *board) setRect(x, y, w, h int) {
 		b.leaveGameGrid.SetRect(image.Rect(x, y, x+dialogWidth, y+dialogHeight))
 	}
 
+	{
+		overlayWidth := b.innerW / 2
+		overlayHeight := game.scale(100)
+		if overlayHeight > b.innerH/4 {
+			overlayHeight = b.innerH / 4
+		}
+
+		x, y := b.x+b.w/2-overlayWidth/2, b.y+b.h/2-overlayHeight/2
+		b.connectionOverlay.SetRect(image.Rect(x, y, x+overlayWidth, y+overlayHeight))
+	}
+
 	b.updateOpponentLabel()
 	b.updatePlayerLabel()
 
 	b.recreateButtonGrid()
 
-	b.menuGrid.SetColumnSizes(-1, game.scale(10), -1, game.scale(10), -1)
+	b.menuGrid.SetColumnSizes(-1, game.scale(10), -1, game.scale(10), -1, game.scale(10), -1, game.scale(10), -1)
+	b.menuGrid.SetRowSizes(-1, game.scale(10), -1)
 
 	b.chatGrid.SetRowSizes(-1, int(b.horizontalBorderSize)/2, inputAndButtons)
 
@@ -1150,11 +1374,12 @@ func (b *board) setRect(x, y, w, h int) {
 }
 
 func (b *board) updateOpponentLabel() {
-	player := b.gameState.OpponentPlayer()
+	state := b.displayState()
+	player := state.OpponentPlayer()
 	label := b.opponentLabel
 
 	var text string
-	if b.gameState.Points > 1 && len(player.Name) > 0 {
+	if state.Points > 1 && len(player.Name) > 0 {
 		text = fmt.Sprintf("%s  %d", player.Name, player.Points)
 	} else {
 		text = player.Name
@@ -1166,7 +1391,7 @@ func (b *board) updateOpponentLabel() {
 	} else {
 		label.activeColor = color.RGBA{255, 255, 255, 255}
 	}
-	label.active = b.gameState.Turn == player.Number
+	label.active = state.Turn == player.Number
 	label.Text.TextField.SetForegroundColor(label.activeColor)
 
 	fontMutex.Lock()
@@ -1186,7 +1411,7 @@ func (b *board) updateOpponentLabel() {
 
 	if b.showPipCount {
 		b.opponentPipCount.SetVisible(true)
-		b.opponentPipCount.SetText(strconv.Itoa(b.gameState.Pips(player.Number)))
+		b.opponentPipCount.SetText(strconv.Itoa(state.Pips(player.Number)))
 		b.opponentPipCount.SetForegroundColor(label.activeColor)
 		b.opponentPipCount.SetRect(image.Rect(x+bounds.Dx(), y-bounds.Dy(), b.w/2-int(b.barWidth)/2, y+bounds.Dy()*2))
 	} else {
@@ -1195,11 +1420,12 @@ func (b *board) updateOpponentLabel() {
 }
 
 func (b *board) updatePlayerLabel() {
-	player := b.gameState.LocalPlayer()
+	state := b.displayState()
+	player := state.LocalPlayer()
 	label := b.playerLabel
 
 	var text string
-	if b.gameState.Points > 1 && len(player.Name) > 0 {
+	if state.Points > 1 && len(player.Name) > 0 {
 		text = fmt.Sprintf("%s  %d", player.Name, player.Points)
 	} else {
 		text = player.Name
@@ -1211,7 +1437,7 @@ func (b *board) updatePlayerLabel() {
 	} else {
 		label.activeColor = color.RGBA{255, 255, 255, 255}
 	}
-	label.active = b.gameState.Turn == player.Number
+	label.active = state.Turn == player.Number
 	label.Text.TextField.SetForegroundColor(label.activeColor)
 
 	fontMutex.Lock()
@@ -1231,7 +1457,7 @@ func (b *board) updatePlayerLabel() {
 
 	if b.showPipCount {
 		b.playerPipCount.SetVisible(true)
-		b.playerPipCount.SetText(strconv.Itoa(b.gameState.Pips(player.Number)))
+		b.playerPipCount.SetText(strconv.Itoa(state.Pips(player.Number)))
 		b.playerPipCount.SetForegroundColor(label.activeColor)
 		b.playerPipCount.SetRect(image.Rect(b.innerW/2+int(b.barWidth)/2+int(b.horizontalBorderSize), y-bounds.Dy(), x, y+bounds.Dy()*2))
 	} else {
@@ -1424,12 +1650,17 @@ func (b *board) processState() {
 	} else if b.gameState.Winner == 0 && b.gameState.Turn != 0 && b.gameState.Turn == b.gameState.PlayerNumber && len(b.gameState.Moves) != 0 {
 		showGrid = b.buttonsOnlyUndoGrid
 	}
+	if !b.connected() || b.viewEntry != nil {
+		showGrid = nil
+	}
 	b.showButtonGrid(showGrid)
+	b.updateConnectionOverlay()
 
+	state := b.displayState()
 	b.Sprites = &Sprites{}
 	b.spaceSprites = make([][]*Sprite, bgammon.BoardSpaces)
 	for space := 0; space < bgammon.BoardSpaces; space++ {
-		spaceValue := b.gameState.Board[space]
+		spaceValue := state.Board[space]
 
 		white := spaceValue < 0
 
@@ -1561,7 +1792,26 @@ func (b *board) playingGame() bool {
 }
 
 func (b *board) playerTurn() bool {
-	return b.playingGame() && (b.gameState.MayRoll() || b.gameState.Turn == b.gameState.PlayerNumber)
+	return b.viewEntry == nil && b.connected() && b.playingGame() && (b.gameState.MayRoll() || b.gameState.Turn == b.gameState.PlayerNumber)
+}
+
+// connected returns whether the client is connected to the server.
+func (b *board) connected() bool {
+	return b.Client == nil || b.Client.Connected()
+}
+
+func (b *board) updateConnectionOverlay() {
+	if b.connected() {
+		b.connectionOverlay.SetVisible(false)
+		return
+	}
+
+	label := gotext.Get("Connecting...")
+	if b.Client.State() == client.StateBackoff {
+		label = gotext.Get("Connection lost. Reconnecting...")
+	}
+	b.connectionOverlay.Label.SetText(label)
+	b.connectionOverlay.SetVisible(true)
 }
 
 func (b *board) startDrag(s *Sprite, space int) {
@@ -1613,7 +1863,7 @@ func (b *board) finishDrag(x int, y int) {
 							b.processState()
 							scheduleFrame()
 							processed = true
-							b.Client.Out <- []byte(fmt.Sprintf("mv %d/%d", space, index))
+							b.Client.Move(space, index)
 						}
 						break ADDPREMOVE
 					}
@@ -1791,3 +2041,64 @@ func (bw *BoardWidget) HandleMouse(cursor image.Point, pressed bool, clicked boo
 	}
 	return true, nil
 }
+
+// subscribe subscribes the board to the events it displays.
+func (b *board) subscribe(d *client.Dispatcher) {
+	d.Subscribe((*bgammon.EventBoard)(nil), b.handleBoard)
+	d.Subscribe((*bgammon.EventRolled)(nil), b.handleRolled)
+	d.Subscribe((*bgammon.EventMoved)(nil), b.handleMoved)
+}
+
+func (b *board) handleBoard(e interface{}) {
+	ev := e.(*bgammon.EventBoard)
+	b.Lock()
+	*b.gameState = ev.GameState
+	*b.gameState.Game = *ev.GameState.Game
+	b.processState()
+	b.Unlock()
+	setViewBoard(true)
+}
+
+func (b *board) handleRolled(e interface{}) {
+	ev := e.(*bgammon.EventRolled)
+	b.Lock()
+	b.gameState.Roll1 = ev.Roll1
+	b.gameState.Roll2 = ev.Roll2
+	var diceFormatted string
+	if b.gameState.Turn == 0 {
+		if b.gameState.Player1.Name == ev.Player {
+			diceFormatted = fmt.Sprintf("%d", b.gameState.Roll1)
+		} else {
+			diceFormatted = fmt.Sprintf("%d", b.gameState.Roll2)
+		}
+		playSoundEffect(effectDie)
+	} else {
+		diceFormatted = fmt.Sprintf("%d-%d", b.gameState.Roll1, b.gameState.Roll2)
+		playSoundEffect(effectDice)
+	}
+	b.processState()
+	b.Unlock()
+	scheduleFrame()
+	lg(gotext.Get("%s rolled %s.", ev.Player, diceFormatted))
+}
+
+func (b *board) handleMoved(e interface{}) {
+	ev := e.(*bgammon.EventMoved)
+	lg(gotext.Get("%s moved %s.", ev.Player, bgammon.FormatMoves(ev.Moves)))
+	playSoundEffect(effectMove)
+	if ev.Player == b.Client.PlayerName() {
+		return
+	}
+	b.Lock()
+	if b.viewEntry != nil {
+		// The position shown is from the move history.
+		b.Unlock()
+		return
+	}
+	atomic.AddInt64(&animationQueue, int64(len(ev.Moves)))
+	for _, move := range ev.Moves {
+		b.movePiece(move[0], move[1])
+		atomic.AddInt64(&animationQueue, -1)
+	}
+	b.Unlock()
+}
@@ -0,0 +1,271 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+)
+
+// Decision is an action chosen by a Strategy.
+type Decision int
+
+const (
+	DecisionWait   Decision = iota // Take no action.
+	DecisionRoll                   // Roll the dice.
+	DecisionDouble                 // Offer a double.
+	DecisionAccept                 // Accept an offered double.
+	DecisionResign                 // Resign, or decline an offered double.
+	DecisionMove                   // Play the returned moves.
+)
+
+func (d Decision) String() string {
+	switch d {
+	case DecisionWait:
+		return "wait"
+	case DecisionRoll:
+		return "roll"
+	case DecisionDouble:
+		return "double"
+	case DecisionAccept:
+		return "accept"
+	case DecisionResign:
+		return "resign"
+	case DecisionMove:
+		return "move"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(d))
+	}
+}
+
+// Strategy decides how a bot plays. Decide is called whenever the bot may
+// act. When the decision is DecisionMove, the returned moves are played in
+// order. Strategies may return a single move or every move of the turn.
+type Strategy interface {
+	Decide(state *bgammon.GameState) (Decision, [][]int)
+}
+
+// NewStrategy returns the strategy with the specified name: random or
+// pubeval. The strength is only used by the pubeval strategy.
+func NewStrategy(name string, strength Strength) (Strategy, error) {
+	switch strings.ToLower(name) {
+	case "random":
+		return &RandomStrategy{}, nil
+	case "pubeval":
+		return NewPubevalStrategy(strength), nil
+	default:
+		return nil, fmt.Errorf("unknown strategy: %s", name)
+	}
+}
+
+// RandomStrategy plays random legal moves. It never doubles and always
+// accepts doubles.
+type RandomStrategy struct{}
+
+func (s *RandomStrategy) Decide(state *bgammon.GameState) (Decision, [][]int) {
+	if state.DoubleOffered && state.DoublePlayer != state.PlayerNumber {
+		return DecisionAccept, nil
+	} else if state.MayRoll() {
+		return DecisionRoll, nil
+	} else if len(state.Available) > 0 {
+		return DecisionMove, [][]int{state.Available[rand.Intn(len(state.Available))]}
+	}
+	return DecisionWait, nil
+}
+
+// BotOptions configures which matches a Bot plays.
+type BotOptions struct {
+	// Create is used to create a match whenever the bot is not playing one.
+	Create *MatchOptions
+
+	// Join is the ID of the match to join. Join is only used when Create is nil.
+	Join         int
+	JoinPassword string
+
+	// Matches is the number of matches to play before disconnecting. When
+	// zero, matches are played until the bot is disconnected.
+	Matches int
+}
+
+// Bot plays matches via a Client using a Strategy.
+type Bot struct {
+	Client   *Client
+	Strategy Strategy
+	Options  BotOptions
+
+	state    *bgammon.GameState
+	queue    [][]int // Moves remaining from the last decision.
+	matches  int
+	playing  bool
+	finished bool // Whether the current match has finished.
+}
+
+// NewBot returns a bot which plays using the provided client and strategy.
+func NewBot(c *Client, strategy Strategy, options BotOptions) *Bot {
+	return &Bot{
+		Client:   c,
+		Strategy: strategy,
+		Options:  options,
+	}
+}
+
+// Run connects to the server and plays matches until the requested number of
+// matches have been played or the client is disconnected.
+func (b *Bot) Run() error {
+	go b.Client.Connect()
+
+	for e := range b.Client.Events {
+		switch ev := e.(type) {
+		case *EventConnectionState:
+			switch ev.State {
+			case StateConnected:
+				b.playing = false
+				b.findMatch()
+			case StateBackoff:
+				log.Printf("Connection lost, reconnecting in %s: %s", time.Until(ev.Retry).Round(time.Second), ev.Err)
+			case StateDisconnected:
+				return nil
+			}
+		case *bgammon.EventWelcome:
+			log.Printf("Logged in as %s", ev.PlayerName)
+		case *bgammon.EventPing:
+			b.Client.Pong(ev.Message)
+		case *bgammon.EventNotice:
+			log.Printf("Notice: %s", ev.Message)
+		case *bgammon.EventJoined:
+			if ev.Player == b.Client.PlayerName() {
+				log.Printf("Joined match %d", ev.GameID)
+				b.playing = true
+				b.finished = false
+				b.queue = nil
+			} else {
+				log.Printf("%s joined the match", ev.Player)
+			}
+		case *bgammon.EventFailedJoin:
+			log.Printf("Failed to join match: %s", ev.Reason)
+			if b.Options.Create == nil {
+				b.Client.Disconnect()
+			}
+		case *bgammon.EventLeft:
+			if ev.Player == b.Client.PlayerName() {
+				b.playing = false
+				b.findMatch()
+			} else {
+				log.Printf("%s left the match", ev.Player)
+			}
+		case *bgammon.EventBoard:
+			state := ev.GameState
+			b.state = &state
+			if state.Winner != 0 && (state.Player1.Points >= state.Points || state.Player2.Points >= state.Points) {
+				b.finishMatch()
+				continue
+			}
+			b.act()
+		case *bgammon.EventFailedRoll, *bgammon.EventFailedMove, *bgammon.EventFailedOk:
+			log.Printf("Command failed: %+v", ev)
+			b.queue = nil
+			b.Client.Board()
+		case *bgammon.EventWin:
+			log.Printf("%s wins %d point(s)", ev.Player, ev.Points)
+		case *EventCommandFailed:
+			log.Printf("Failed to send command %s: %s", ev.Command, ev.Err)
+		}
+	}
+	return nil
+}
+
+// finishMatch leaves the finished match to create another, or disconnects
+// when no more matches will be played.
+func (b *Bot) finishMatch() {
+	if b.finished {
+		return
+	}
+	b.finished = true
+	b.matches++
+	log.Printf("Match finished (%d played)", b.matches)
+	if b.Options.Create == nil || (b.Options.Matches > 0 && b.matches >= b.Options.Matches) {
+		b.Client.Disconnect()
+		return
+	}
+	b.Client.Leave()
+}
+
+// findMatch creates or joins a match as specified by the bot options.
+func (b *Bot) findMatch() {
+	if b.playing {
+		return
+	}
+	var err error
+	if b.Options.Create != nil {
+		err = b.Client.CreateMatch(*b.Options.Create)
+	} else if b.Options.Join != 0 {
+		err = b.Client.Join(b.Options.Join, b.Options.JoinPassword)
+	}
+	if err != nil {
+		log.Printf("error: failed to find match: %s", err)
+		b.Client.Disconnect()
+	}
+}
+
+// act consults the strategy and sends the resulting commands.
+func (b *Bot) act() {
+	state := b.state
+	if state.Spectating || state.Winner != 0 {
+		return
+	}
+
+	doubleOffered := state.DoubleOffered && state.DoublePlayer != state.PlayerNumber
+	if !doubleOffered && !state.MayRoll() && state.Turn != state.PlayerNumber {
+		return
+	}
+
+	if len(b.queue) > 0 && containsMove(state.Available, b.queue[0]) {
+		b.playMove()
+		return
+	}
+	b.queue = nil
+
+	if !doubleOffered && !state.MayRoll() && len(state.Available) == 0 {
+		if state.MayOK() {
+			b.Client.Submit()
+		}
+		return
+	}
+
+	decision, moves := b.Strategy.Decide(state)
+	switch decision {
+	case DecisionRoll:
+		b.Client.Roll()
+	case DecisionDouble:
+		b.Client.Double()
+	case DecisionAccept:
+		b.Client.Submit()
+	case DecisionResign:
+		b.Client.Resign()
+	case DecisionMove:
+		if len(moves) == 0 {
+			return
+		}
+		b.queue = moves
+		b.playMove()
+	}
+}
+
+// playMove plays the next queued move.
+func (b *Bot) playMove() {
+	move := b.queue[0]
+	b.queue = b.queue[1:]
+	b.Client.Move(move[0], move[1])
+}
+
+func containsMove(moves [][]int, move []int) bool {
+	for _, m := range moves {
+		if len(m) >= 2 && len(move) >= 2 && m[0] == move[0] && m[1] == move[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+	"code.rocket9labs.com/tslocum/boxcars/game/fakeserver"
+)
+
+// TestBots plays a match between a bot which makes random moves and a bot
+// which plays as the second player using pubeval.
+func TestBots(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+	})
+
+	server := fakeserver.NewServer()
+	observer := pipeClient(server, "Observer")
+	go observer.Connect()
+	defer observer.Disconnect()
+	waitForState(t, observer, StateConnected)
+
+	run := func(b *Bot) chan error {
+		done := make(chan error, 1)
+		go func() {
+			done <- b.Run()
+		}()
+		return done
+	}
+	creator := NewBot(pipeClient(server, "Creator"), &RandomStrategy{}, BotOptions{
+		Create:  &MatchOptions{Name: "Bot match", Points: 1},
+		Matches: 1,
+	})
+	creatorDone := run(creator)
+
+	// Wait for the match to be created before joining it.
+	var matchID int
+	eventually(t, func() bool {
+		observer.List()
+		list := waitFor(t, observer, func(ev interface{}) bool {
+			_, ok := ev.(*bgammon.EventList)
+			return ok
+		}).(*bgammon.EventList)
+		if len(list.Games) == 0 {
+			return false
+		}
+		matchID = list.Games[0].ID
+		return true
+	})
+	joiner := NewBot(pipeClient(server, "Joiner"), NewPubevalStrategy(StrengthExpert), BotOptions{
+		Join: matchID,
+	})
+	joinerDone := run(joiner)
+
+	timeout := time.After(30 * time.Second)
+	for _, done := range []chan error{creatorDone, joinerDone} {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-timeout:
+			creator.Client.Disconnect()
+			joiner.Client.Disconnect()
+			t.Fatal("timed out waiting for the match to finish")
+		}
+	}
+	if creator.matches != 1 || joiner.matches != 1 {
+		t.Fatalf("expected both bots to finish one match, finished %d and %d", creator.matches, joiner.matches)
+	}
+}
+
+func TestNewStrategy(t *testing.T) {
+	if s, err := NewStrategy("random", StrengthExpert); err != nil {
+		t.Fatal(err)
+	} else if _, ok := s.(*RandomStrategy); !ok {
+		t.Fatalf("expected random strategy, got %T", s)
+	}
+
+	strength, err := ParseStrength("Beginner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := NewStrategy("pubeval", strength); err != nil {
+		t.Fatal(err)
+	} else if p, ok := s.(*PubevalStrategy); !ok || p.Strength != StrengthBeginner {
+		t.Fatalf("expected beginner pubeval strategy, got %+v", s)
+	}
+
+	if _, err := NewStrategy("gnubg", StrengthExpert); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	} else if _, err := ParseStrength("master"); err == nil {
+		t.Fatal("expected error for unknown strength")
+	}
+}
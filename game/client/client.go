@@ -0,0 +1,551 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+)
+
+// ConnectionState represents the state of the connection between a Client
+// and the server.
+type ConnectionState int
+
+const (
+	StateDisconnected ConnectionState = iota
+	StateDialing
+	StateAuthenticating
+	StateConnected
+	StateBackoff
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateDialing:
+		return "dialing"
+	case StateAuthenticating:
+		return "authenticating"
+	case StateConnected:
+		return "connected"
+	case StateBackoff:
+		return "backoff"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(s))
+	}
+}
+
+// EventConnectionState is sent via Client.Events whenever the connection
+// state changes.
+type EventConnectionState struct {
+	State ConnectionState
+
+	// Attempt is the number of consecutive failed connection attempts.
+	Attempt int
+
+	// Retry is the time of the next connection attempt while backing off.
+	Retry time.Time
+
+	// Err is the error which caused the connection to be lost, if any.
+	Err error
+}
+
+const (
+	backoffMin    = time.Second
+	backoffMax    = time.Minute
+	backoffJitter = 0.25
+)
+
+var errConnectionClosed = errors.New("connection closed")
+
+// ErrRegistrationFailed is the error of the EventConnectionState sent when
+// the server closes the connection while registering an account. Other
+// connection errors while registering are retried.
+var ErrRegistrationFailed = errors.New("registration failed")
+
+// backoffDelay returns the delay before the specified connection attempt.
+// The delay doubles with each attempt, up to backoffMax, and is randomized
+// to prevent clients from reconnecting in lockstep after a server restart.
+func backoffDelay(attempt int) time.Duration {
+	d := backoffMax
+	if attempt < 16 {
+		d = backoffMin << attempt
+		if d > backoffMax {
+			d = backoffMax
+		}
+	}
+	jitter := time.Duration(float64(d) * backoffJitter)
+	return d - jitter + time.Duration(rand.Int63n(int64(jitter)*2+1))
+}
+
+type Client struct {
+	metrics clientMetrics // Must be first to be 64-bit aligned on 32-bit platforms.
+
+	Address    string
+	Username   string
+	Password   string
+	Email      string // Email address used when registering.
+	Register   bool   // Register an account instead of logging in.
+	Events     chan interface{}
+	connecting bool
+
+	// queue holds commands until they are sent to the server.
+	queue *commandQueue
+
+	state   ConnectionState
+	attempt int
+	retryAt time.Time
+
+	stop      chan struct{}
+	retry     chan struct{}
+	closeConn func()
+
+	playerName string // Name assigned by the server when logging in.
+
+	stateLock *sync.Mutex
+
+	// NewTransport returns the transport used for each connection attempt.
+	// When nil, a transport is selected based on the scheme of the server
+	// address.
+	NewTransport func() Transport
+
+	// TLS configures connections to servers using the tls:// scheme.
+	TLS *TLSOptions
+
+	// Proxy is the proxy used to connect to the server. When nil, the proxy
+	// specified via the ALL_PROXY or HTTPS_PROXY environment variables is used.
+	Proxy *url.URL
+
+	recorder      *Recorder
+	recordSession int // Session number used to tag recorded messages.
+
+	latency latencyStats
+
+	capabilities  map[string]bool
+	helpRequested bool // The response to the help command sent after logging in is pending.
+	rawEvents     []*RawEvent
+}
+
+// Debug is the debug level. Commands sent and events received are logged when
+// greater than zero.
+var Debug int
+
+// NewClient returns a client configured to connect to the specified server.
+func NewClient(address string, username string, password string) *Client {
+	const bufferSize = 10
+	return &Client{
+		Address:   address,
+		Username:  username,
+		Password:  password,
+		Events:    make(chan interface{}, bufferSize),
+		queue:     newCommandQueue(),
+		retry:     make(chan struct{}, 1),
+		stateLock: &sync.Mutex{},
+	}
+}
+
+// Connect connects to the server and reconnects with exponential backoff
+// whenever the connection is lost. Connect returns after Disconnect is called.
+func (c *Client) Connect() {
+	c.stateLock.Lock()
+	if c.connecting {
+		c.stateLock.Unlock()
+		return
+	}
+	c.connecting = true
+	c.stop = make(chan struct{})
+	stop := c.stop
+	c.stateLock.Unlock()
+
+	for {
+		c.stateLock.Lock()
+		reconnect := c.attempt > 0
+		c.stateLock.Unlock()
+		if reconnect {
+			atomic.AddUint64(&c.metrics.reconnects, 1)
+		}
+		err := c.connect()
+
+		select {
+		case <-stop:
+			c.setState(StateDisconnected, nil)
+			return
+		default:
+		}
+
+		// Credentials which may not be sent are not retried, and neither
+		// are registrations rejected by the server.
+		c.stateLock.Lock()
+		if errors.Is(err, ErrInvalidArgument) || errors.Is(err, ErrRegistrationFailed) {
+			c.connecting = false
+			c.stateLock.Unlock()
+			c.setState(StateDisconnected, err)
+			return
+		}
+		c.attempt++
+		delay := backoffDelay(c.attempt)
+		c.retryAt = time.Now().Add(delay)
+		c.stateLock.Unlock()
+
+		c.setState(StateBackoff, err)
+
+		if !c.backoff(delay, stop) {
+			c.setState(StateDisconnected, nil)
+			return
+		}
+	}
+}
+
+// backoff waits before the next connection attempt and returns false when
+// the client is disconnected while waiting. Commands dropped while waiting
+// are reported immediately.
+func (c *Client) backoff(delay time.Duration, stop chan struct{}) bool {
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			return true
+		case <-c.retry:
+			return true
+		case <-c.queue.failedReady:
+			c.sendEvent(nil)
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// Disconnect closes the connection to the server and stops reconnecting.
+func (c *Client) Disconnect() {
+	c.stateLock.Lock()
+	if !c.connecting {
+		c.stateLock.Unlock()
+		return
+	}
+	c.connecting = false
+	close(c.stop)
+	closeConn := c.closeConn
+	c.stateLock.Unlock()
+
+	if closeConn != nil {
+		closeConn()
+	}
+}
+
+// Retry skips the remaining backoff delay and attempts to reconnect
+// immediately. Retry has no effect unless the client is backing off.
+func (c *Client) Retry() {
+	if c.State() != StateBackoff {
+		return
+	}
+	select {
+	case c.retry <- struct{}{}:
+	default:
+	}
+}
+
+// State returns the current connection state.
+func (c *Client) State() ConnectionState {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	return c.state
+}
+
+// Connected returns whether the client is connected and logged in.
+func (c *Client) Connected() bool {
+	return c.State() == StateConnected
+}
+
+// RetryAt returns the time of the next connection attempt while backing off.
+func (c *Client) RetryAt() time.Time {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	return c.retryAt
+}
+
+func (c *Client) setState(state ConnectionState, err error) {
+	c.stateLock.Lock()
+	if c.state == state && err == nil {
+		c.stateLock.Unlock()
+		return
+	}
+	c.state = state
+	if state == StateConnected {
+		c.attempt = 0
+		c.Register = false
+	}
+	if state != StateBackoff {
+		c.retryAt = time.Time{}
+	}
+	ev := &EventConnectionState{
+		State:   state,
+		Attempt: c.attempt,
+		Retry:   c.retryAt,
+		Err:     err,
+	}
+	c.stateLock.Unlock()
+
+	c.sendEvent(ev)
+}
+
+func (c *Client) setCloseConn(f func()) {
+	c.stateLock.Lock()
+	c.closeConn = f
+	c.stateLock.Unlock()
+}
+
+// connect connects to the server and returns when the connection is lost.
+func (c *Client) connect() error {
+	c.setState(StateDialing, nil)
+
+	var t Transport
+	if c.NewTransport != nil {
+		t = c.NewTransport()
+	} else {
+		t = newTransport(c.Address, c.TLS, resolveProxy(c.Proxy))
+	}
+	login, err := c.logIn()
+	if err != nil {
+		return err
+	}
+	err = t.Dial(c.Address)
+	if err != nil {
+		return err
+	}
+	c.setCloseConn(func() {
+		t.Close()
+	})
+	defer c.setCloseConn(nil)
+
+	c.resetLatency()
+	c.setState(StateAuthenticating, nil)
+	c.setCapabilities(nil)
+	c.stateLock.Lock()
+	c.helpRequested = true
+	c.stateLock.Unlock()
+
+	for _, msg := range bytes.Split(login, []byte("\n")) {
+		if len(msg) == 0 {
+			continue
+		}
+
+		c.listSent(msg)
+		err = t.Write(msg)
+		if err != nil {
+			t.Close()
+			return err
+		}
+		atomic.AddUint64(&c.metrics.commands, 1)
+		c.record(DirectionOut, msg)
+	}
+
+	expired, stale := c.queue.expire()
+	for _, cmd := range expired {
+		c.commandFailed(cmd, errCommandExpired)
+	}
+	for _, cmd := range stale {
+		c.commandFailed(cmd, errCommandStale)
+	}
+
+	done := make(chan struct{})
+	go c.handleWrite(t, done)
+	go c.keepAlive(t, done)
+	err = c.handleRead(t)
+	close(done)
+	t.Close()
+
+	// The server closes the connection when registration fails.
+	c.stateLock.Lock()
+	rejected := c.Register && c.state == StateAuthenticating && isClosed(err)
+	c.stateLock.Unlock()
+	if rejected {
+		return fmt.Errorf("%w: %s", ErrRegistrationFailed, err)
+	}
+	return err
+}
+
+// logIn returns the commands sent to log in, or to register when requested.
+// An error is returned when the username or email address contains
+// whitespace.
+func (c *Client) logIn() ([]byte, error) {
+	username, err := tokenArgument(c.PlayerName())
+	if err != nil {
+		return nil, fmt.Errorf("invalid username: %w", err)
+	}
+	password := textArgument(c.Password)
+	if c.Register {
+		email, err := tokenArgument(c.Email)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email address: %w", err)
+		}
+		return []byte(fmt.Sprintf("rj %s %s %s %s\nversion %d\nhelp\nlist\n", APPNAME, email, username, password, ProtocolVersion)), nil
+	}
+	loginInfo := username
+	if username != "" && password != "" {
+		loginInfo = username + " " + password
+	}
+	return []byte(fmt.Sprintf("lj %s %s\nversion %d\nhelp\nlist\n", APPNAME, loginInfo, ProtocolVersion)), nil
+}
+
+// PlayerName returns the name assigned by the server when logging in, or
+// Username when the client has not logged in.
+func (c *Client) PlayerName() string {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	if c.playerName != "" {
+		return c.playerName
+	}
+	return c.Username
+}
+
+// ResetPassword requests an email containing a link to reset the password of
+// the account associated with the specified email address. The response of
+// the server is returned. ResetPassword uses a separate connection and may be
+// called while the client is connected.
+func (c *Client) ResetPassword(email string) (string, error) {
+	email, err := tokenArgument(email)
+	if err != nil {
+		return "", fmt.Errorf("invalid email address: %w", err)
+	}
+	t := newTransport(c.Address, c.TLS, resolveProxy(c.Proxy))
+	err = t.Dial(c.Address)
+	if err != nil {
+		return "", err
+	}
+	defer t.Close()
+
+	err = t.Write([]byte("resetpassword " + email))
+	if err != nil {
+		return "", err
+	}
+
+	timer := time.AfterFunc(readTimeout, func() {
+		t.Close()
+	})
+	defer timer.Stop()
+	for {
+		msg, err := t.Read()
+		if err != nil {
+			return "", err
+		}
+
+		ev, err := bgammon.DecodeEvent(msg)
+		if err != nil {
+			return "", err
+		} else if notice, ok := ev.(*bgammon.EventNotice); ok {
+			return notice.Message, nil
+		}
+	}
+}
+
+func (c *Client) LoggedIn() bool {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	return c.connecting
+}
+
+// handleEvent passes an event received from the server to Client.Events.
+func (c *Client) handleEvent(ev interface{}) {
+	switch ev := ev.(type) {
+	case *bgammon.EventWelcome:
+		// The name is used when logging in again after reconnecting.
+		c.stateLock.Lock()
+		c.playerName = ev.PlayerName
+		c.stateLock.Unlock()
+		c.setState(StateConnected, nil)
+	case *bgammon.EventHelp:
+		if c.takeHelpRequested() {
+			// The capabilities of the server are advertised by the help
+			// text sent in response to the help command sent after
+			// logging in.
+			capabilities := capabilitiesFromHelp(ev.Message)
+			c.setCapabilities(capabilities)
+			c.sendEvent(&EventCapabilities{Capabilities: capabilities})
+			return
+		}
+	case *bgammon.EventPing:
+		if c.pingReceived(ev.Message) {
+			return
+		}
+	case *bgammon.EventList:
+		if c.listReceived() {
+			return
+		}
+	}
+	c.sendEvent(ev)
+}
+
+// takeHelpRequested returns whether the response to the help command sent
+// after logging in is pending, and marks it as received.
+func (c *Client) takeHelpRequested() bool {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	requested := c.helpRequested
+	c.helpRequested = false
+	return requested
+}
+
+func (c *Client) handleWrite(t Transport, done chan struct{}) {
+	for {
+		cmd := c.queue.pop()
+		if cmd == nil {
+			select {
+			case <-c.queue.ready:
+				continue
+			case <-done:
+				return
+			}
+		}
+
+		c.listSent(cmd.data)
+		err := t.Write(cmd.data)
+		if err != nil {
+			// The command is sent after reconnecting.
+			c.queue.unpop(cmd)
+			t.Close()
+			return
+		}
+		atomic.AddUint64(&c.metrics.commands, 1)
+		c.record(DirectionOut, cmd.data)
+
+		if Debug > 0 {
+			log.Printf("-> %s", redactCommand(string(cmd.data)))
+		}
+	}
+}
+
+func (c *Client) handleRead(t Transport) error {
+	for {
+		msg, err := t.Read()
+		if err != nil {
+			return err
+		}
+		c.record(DirectionIn, msg)
+
+		ev, err := bgammon.DecodeEvent(msg)
+		if err != nil {
+			// Keep messages which could not be decoded, as they may have
+			// been sent by a newer version of the server.
+			c.addRawEvent(msg, err)
+			if Debug > 0 {
+				log.Printf("warning: failed to parse message: %s", msg)
+			}
+			continue
+		}
+		c.countEvent(ev)
+		c.handleEvent(ev)
+
+		if Debug > 0 {
+			log.Printf("<- %s", msg)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build android
+
+package client
+
+const (
+	DefaultServerAddress = "wss://ws.bgammon.org"
+	APPNAME              = "boxcars-android"
+)
@@ -0,0 +1,8 @@
+//go:build (!js || !wasm) && !android
+
+package client
+
+const (
+	DefaultServerAddress = "tcp://bgammon.org:1337"
+	APPNAME              = "boxcars"
+)
@@ -0,0 +1,545 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+	"code.rocket9labs.com/tslocum/boxcars/game/fakeserver"
+)
+
+// testTimeout is the time allowed for each expected event to be received.
+const testTimeout = 5 * time.Second
+
+// waitFor reads events from the client until match returns true, and returns
+// the matching event.
+func waitFor(t *testing.T, c *Client, match func(ev interface{}) bool) interface{} {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case ev := <-c.Events:
+			if match(ev) {
+				return ev
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+// waitForState reads events from the client until the connection enters the
+// specified state.
+func waitForState(t *testing.T, c *Client, state ConnectionState) *EventConnectionState {
+	t.Helper()
+	return waitFor(t, c, func(ev interface{}) bool {
+		s, ok := ev.(*EventConnectionState)
+		return ok && s.State == state
+	}).(*EventConnectionState)
+}
+
+// pipeClient returns a client which connects to the provided server via an
+// in-memory pipe.
+func pipeClient(server *fakeserver.Server, username string) *Client {
+	clientEnd, serverEnd := NewPipeTransport()
+	go server.ServeConn(serverEnd)
+
+	c := NewClient("pipe", username, "")
+	c.NewTransport = func() Transport {
+		return clientEnd
+	}
+	return c
+}
+
+// waitForBoard reads events from the client until a game state for which
+// match returns true is received.
+func waitForBoard(t *testing.T, c *Client, match func(state *bgammon.GameState) bool) *bgammon.GameState {
+	t.Helper()
+	ev := waitFor(t, c, func(ev interface{}) bool {
+		b, ok := ev.(*bgammon.EventBoard)
+		return ok && match(&b.GameState)
+	}).(*bgammon.EventBoard)
+	return &ev.GameState
+}
+
+func TestBackoffDelay(t *testing.T) {
+	for attempt := 0; attempt < 32; attempt++ {
+		expected := backoffMax
+		if attempt < 16 && backoffMin<<attempt < backoffMax {
+			expected = backoffMin << attempt
+		}
+		min := time.Duration(float64(expected) * (1 - backoffJitter))
+		max := time.Duration(float64(expected) * (1 + backoffJitter))
+		for i := 0; i < 10; i++ {
+			if d := backoffDelay(attempt); d < min || d > max {
+				t.Fatalf("attempt %d: expected delay between %s and %s, got %s", attempt, min, max, d)
+			}
+		}
+	}
+}
+
+func TestLogIn(t *testing.T) {
+	testCases := []struct {
+		username, password string
+		register           bool
+		email              string
+		expected           string
+		err                error
+	}{
+		{"", "", false, "", "lj boxcars \nversion 1\nhelp\nlist\n", nil},
+		{"player", "", false, "", "lj boxcars player\nversion 1\nhelp\nlist\n", nil},
+		{"player", "pass word", false, "", "lj boxcars player pass word\nversion 1\nhelp\nlist\n", nil},
+		{"player", "pass\nword", false, "", "lj boxcars player password\nversion 1\nhelp\nlist\n", nil},
+		{"play er", "password", false, "", "", ErrInvalidArgument},
+		{"player", "password", true, "player@example.com", "rj boxcars player@example.com player password\nversion 1\nhelp\nlist\n", nil},
+		{"player", "password", true, "player @example.com", "", ErrInvalidArgument},
+	}
+	for _, tc := range testCases {
+		c := NewClient("pipe", tc.username, tc.password)
+		c.Register = tc.register
+		c.Email = tc.email
+		login, err := c.logIn()
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%q: expected error %v, got %v", tc.username, tc.err, err)
+		} else if string(login) != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.username, tc.expected, login)
+		}
+	}
+}
+
+func TestInvalidUsernameNotRetried(t *testing.T) {
+	c := pipeClient(fakeserver.NewServer(), "play er")
+	go c.Connect()
+	defer c.Disconnect()
+
+	ev := waitFor(t, c, func(ev interface{}) bool {
+		s, ok := ev.(*EventConnectionState)
+		return ok && (s.State == StateDisconnected || s.State == StateBackoff)
+	}).(*EventConnectionState)
+	if ev.State != StateDisconnected || !errors.Is(ev.Err, ErrInvalidArgument) {
+		t.Fatalf("expected to disconnect with %v, got state %v with %v", ErrInvalidArgument, ev.State, ev.Err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	server := fakeserver.NewServer()
+	c := pipeClient(server, "player")
+	go c.Connect()
+	defer c.Disconnect()
+
+	ev := waitFor(t, c, func(ev interface{}) bool {
+		_, ok := ev.(*EventCapabilities)
+		return ok
+	}).(*EventCapabilities)
+	expected := []string{CapabilityRegister, CapabilityPing}
+	if !reflect.DeepEqual(ev.Capabilities, expected) {
+		t.Fatalf("expected capabilities %v, got %v", expected, ev.Capabilities)
+	}
+	if !c.HasCapability(CapabilityPing) || c.HasCapability(CapabilityTV) {
+		t.Fatalf("unexpected capabilities: %v", c.capabilities)
+	}
+
+	// Help requested by the user is passed on.
+	c.Send("help")
+	waitFor(t, c, func(ev interface{}) bool {
+		_, ok := ev.(*bgammon.EventHelp)
+		return ok
+	})
+}
+
+func TestUnknownCapabilities(t *testing.T) {
+	c := NewClient("pipe", "player", "")
+	if !c.HasCapability(CapabilityTV) || c.advertised(CapabilityPing) {
+		t.Fatal("expected capabilities to be enabled but not advertised before they are known")
+	}
+
+	c.setCapabilities(capabilitiesFromHelp("Commands are not listed."))
+	if !c.HasCapability(CapabilityWatch) || c.advertised(CapabilityWatch) {
+		t.Fatal("expected capabilities to be enabled but not advertised when the help text is not understood")
+	}
+
+	c.setCapabilities([]string{CapabilityPing})
+	if c.HasCapability(CapabilityWatch) || !c.advertised(CapabilityPing) {
+		t.Fatalf("unexpected capabilities: %v", c.capabilities)
+	}
+}
+
+func TestCapabilitiesFromHelp(t *testing.T) {
+	help := "help - Print this help text.\n/watch [id] - Watch a match.\nTV - Watch matches continuously.\n\nrematch\nunknown - Not a capability."
+	expected := []string{CapabilityWatch, CapabilityTV, CapabilityRematch}
+	if capabilities := capabilitiesFromHelp(help); !reflect.DeepEqual(capabilities, expected) {
+		t.Fatalf("expected capabilities %v, got %v", expected, capabilities)
+	}
+}
+
+// TestRawEvents tests that messages which could not be decoded are kept
+// instead of closing the connection.
+func TestRawEvents(t *testing.T) {
+	server := fakeserver.NewServer()
+	server.Handler = func(c *fakeserver.Conn, command string, args []string) bool {
+		if command == "list" {
+			c.Send(map[string]string{"Type": "future"})
+		}
+		return false
+	}
+	c := pipeClient(server, "player")
+	go c.Connect()
+	defer c.Disconnect()
+
+	waitFor(t, c, func(ev interface{}) bool {
+		_, ok := ev.(*bgammon.EventList)
+		return ok
+	})
+	events := c.RawEvents()
+	if len(events) != 1 || string(events[0].Data) != `{"Type":"future"}` || events[0].Err == nil {
+		t.Fatalf("unexpected raw events: %+v", events)
+	} else if !c.Connected() {
+		t.Fatal("expected client to remain connected")
+	}
+}
+
+func TestPingReceived(t *testing.T) {
+	c := NewClient("pipe", "", "")
+	if c.pingReceived("1") {
+		t.Fatal("expected ping to be ignored when no ping is pending")
+	}
+
+	c.latency.ping = 1
+	c.latency.pingSent = time.Now().Add(-50 * time.Millisecond)
+	if c.pingReceived("2") {
+		t.Fatal("expected ping with a different message to be ignored")
+	} else if !c.pingReceived("1") {
+		t.Fatal("expected pending ping to be received")
+	} else if c.pingReceived("1") {
+		t.Fatal("expected ping to be received only once")
+	}
+	if average, _ := c.Latency(); average < 50*time.Millisecond {
+		t.Fatalf("expected latency of at least 50ms, got %s", average)
+	}
+}
+
+func TestListReceived(t *testing.T) {
+	c := NewClient("pipe", "", "")
+	c.listSent([]byte("board"))
+	if c.listReceived() {
+		t.Fatal("expected list to be ignored when no list request is pending")
+	}
+
+	c.listSent([]byte("list"))
+	c.latency.lists[0].sent = time.Now().Add(-50 * time.Millisecond)
+	c.latency.lists = append(c.latency.lists, listRequest{sent: time.Now(), probe: true})
+	if c.listReceived() {
+		t.Fatal("expected response to the list request sent by the user to be passed on")
+	} else if !c.listReceived() {
+		t.Fatal("expected response to the list request sent by keepAlive to be discarded")
+	}
+	if average, _ := c.Latency(); average < 25*time.Millisecond {
+		t.Fatalf("expected latency of at least 25ms, got %s", average)
+	}
+
+	c.setCapabilities([]string{CapabilityPing})
+	c.listSent([]byte("ls"))
+	if len(c.latency.lists) != 0 {
+		t.Fatal("expected list requests not to be measured when pings are echoed")
+	}
+}
+
+// TestMatch plays a match from logging in until it is won.
+func TestMatch(t *testing.T) {
+	server := fakeserver.NewServer()
+	server.Dice = fakeserver.FixedDice(6, 1)
+
+	a := pipeClient(server, "Alice")
+	go a.Connect()
+	defer a.Disconnect()
+	b := pipeClient(server, "Bob")
+	go b.Connect()
+	defer b.Disconnect()
+
+	waitForState(t, a, StateConnected)
+	waitForState(t, b, StateConnected)
+
+	err := a.CreateMatch(MatchOptions{Name: "Test match", Points: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := waitFor(t, a, func(ev interface{}) bool {
+		_, ok := ev.(*bgammon.EventJoined)
+		return ok
+	}).(*bgammon.EventJoined)
+
+	b.List()
+	list := waitFor(t, b, func(ev interface{}) bool {
+		l, ok := ev.(*bgammon.EventList)
+		return ok && len(l.Games) == 1
+	}).(*bgammon.EventList)
+	if g := list.Games[0]; g.ID != joined.GameID || g.Name != "Test match" || g.Points != 1 || g.Players != 1 {
+		t.Fatalf("unexpected match listing: %+v", g)
+	}
+
+	err = b.Join(joined.GameID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForBoard(t, b, func(state *bgammon.GameState) bool {
+		return state.PlayerNumber == 2 && state.Player1.Name == "Alice" && state.Player2.Name == "Bob"
+	})
+
+	// Alice rolls a 6 and Bob rolls a 1, so Alice moves first.
+	a.Roll()
+	waitForBoard(t, a, func(state *bgammon.GameState) bool {
+		return state.Roll1 == 6
+	})
+	b.Roll()
+	state := waitForBoard(t, a, func(state *bgammon.GameState) bool {
+		return state.Turn == 1
+	})
+	if state.Roll1 != 6 || state.Roll2 != 1 || len(state.Available) == 0 {
+		t.Fatalf("unexpected game state after the opening roll: %+v", state)
+	}
+
+	a.Move(13, 7)
+	a.Move(8, 7)
+	state = waitForBoard(t, a, func(state *bgammon.GameState) bool {
+		return len(state.Available) == 0
+	})
+	if state.Board[13] != 4 || state.Board[8] != 2 || state.Board[7] != 2 {
+		t.Fatalf("unexpected board after moving: %v", state.Board)
+	}
+	a.Submit()
+	waitForBoard(t, b, func(state *bgammon.GameState) bool {
+		return state.Turn == 2 && state.MayRoll()
+	})
+
+	b.Resign()
+	win := waitFor(t, a, func(ev interface{}) bool {
+		_, ok := ev.(*bgammon.EventWin)
+		return ok
+	}).(*bgammon.EventWin)
+	if win.Player != "Alice" || win.Points != 1 {
+		t.Fatalf("unexpected win: %+v", win)
+	}
+	state = waitForBoard(t, b, func(state *bgammon.GameState) bool {
+		return state.Winner != 0
+	})
+	if state.Winner != 1 || state.Player1.Points != 1 || state.Player2.Points != 0 {
+		t.Fatalf("unexpected game state after winning: %+v", state)
+	}
+}
+
+// TestBearOffAsPlayer2 checks that the second player is sent the board from
+// their own side and is able to bear off using the spaces they are sent.
+func TestBearOffAsPlayer2(t *testing.T) {
+	server := fakeserver.NewServer()
+	server.Dice = fakeserver.FixedDice(1, 6)
+	server.Board = func() []int {
+		// Bob has a single checker left on his one point.
+		board := make([]int, bgammon.BoardSpaces)
+		board[13] = 15
+		board[24] = -1
+		board[bgammon.SpaceHomeOpponent] = -14
+		return board
+	}
+
+	a := pipeClient(server, "Alice")
+	go a.Connect()
+	defer a.Disconnect()
+	b := pipeClient(server, "Bob")
+	go b.Connect()
+	defer b.Disconnect()
+
+	waitForState(t, a, StateConnected)
+	waitForState(t, b, StateConnected)
+
+	err := a.CreateMatch(MatchOptions{Name: "Test match", Points: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := waitFor(t, a, func(ev interface{}) bool {
+		_, ok := ev.(*bgammon.EventJoined)
+		return ok
+	}).(*bgammon.EventJoined)
+	err = b.Join(joined.GameID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForBoard(t, b, func(state *bgammon.GameState) bool {
+		return state.PlayerNumber == 2
+	})
+
+	// Alice rolls a 1 and Bob rolls a 6, so Bob moves first.
+	a.Roll()
+	waitForBoard(t, b, func(state *bgammon.GameState) bool {
+		return state.Roll1 == 1
+	})
+	b.Roll()
+	state := waitForBoard(t, b, func(state *bgammon.GameState) bool {
+		return state.Turn == 2
+	})
+	if state.Board[1] != -1 || state.Board[bgammon.SpaceHomePlayer] != -14 || state.Board[12] != 15 {
+		t.Fatalf("expected board from the side of player 2, got %v", state.Board)
+	} else if !containsMove(state.Available, []int{1, bgammon.SpaceHomePlayer}) {
+		t.Fatalf("expected bearing off to be available, got %v", state.Available)
+	}
+
+	b.Move(1, bgammon.SpaceHomePlayer)
+	moved := waitFor(t, b, func(ev interface{}) bool {
+		_, ok := ev.(*bgammon.EventMoved)
+		return ok
+	}).(*bgammon.EventMoved)
+	if !reflect.DeepEqual(moved.Moves, [][]int{{1, bgammon.SpaceHomePlayer}}) {
+		t.Fatalf("expected move from the side of player 2, got %v", moved.Moves)
+	}
+	win := waitFor(t, a, func(ev interface{}) bool {
+		_, ok := ev.(*bgammon.EventWin)
+		return ok
+	}).(*bgammon.EventWin)
+	if win.Player != "Bob" {
+		t.Fatalf("unexpected win: %+v", win)
+	}
+}
+
+// reconnectingClient returns a client which connects to the provided server
+// via a new in-memory pipe for each connection attempt. The server end of
+// each pipe is sent via conns.
+func reconnectingClient(server *fakeserver.Server, username string, conns chan *PipeTransport) *Client {
+	c := NewClient("pipe", username, "")
+	c.NewTransport = func() Transport {
+		clientEnd, serverEnd := NewPipeTransport()
+		go server.ServeConn(serverEnd)
+		conns <- serverEnd
+		return clientEnd
+	}
+	return c
+}
+
+func TestReconnect(t *testing.T) {
+	server := fakeserver.NewServer()
+	logins := make(chan string, 2)
+	server.Handler = func(c *fakeserver.Conn, command string, args []string) bool {
+		if command == "lj" {
+			logins <- strings.Join(args, " ")
+		}
+		return false
+	}
+	conns := make(chan *PipeTransport, 2)
+	c := reconnectingClient(server, "", conns)
+	go c.Connect()
+	defer c.Disconnect()
+	waitForState(t, c, StateConnected)
+	name := c.PlayerName()
+	if login := <-logins; login != "boxcars" {
+		t.Fatalf("unexpected login: %q", login)
+	} else if !strings.HasPrefix(name, "Guest") {
+		t.Fatalf("expected name assigned by the server, got %q", name)
+	}
+
+	(<-conns).Close()
+	ev := waitForState(t, c, StateBackoff)
+	if ev.Attempt != 1 || ev.Retry.IsZero() || !errors.Is(ev.Err, errConnectionClosed) {
+		t.Fatalf("unexpected connection state: %+v", ev)
+	} else if !c.RetryAt().Equal(ev.Retry) {
+		t.Fatalf("expected retry at %s, got %s", ev.Retry, c.RetryAt())
+	}
+
+	// Commands which act on the match are not sent after reconnecting, as
+	// the match may have changed while disconnected. Other commands are
+	// sent once the connection is restored.
+	c.Roll()
+	c.Say("hello")
+
+	// The name assigned by the server is used when logging in again.
+	c.Retry()
+	failed := waitFor(t, c, func(ev interface{}) bool {
+		_, ok := ev.(*EventCommandFailed)
+		return ok
+	}).(*EventCommandFailed)
+	if failed.Command != "roll" || !errors.Is(failed.Err, errCommandStale) {
+		t.Fatalf("unexpected command failure: %+v", failed)
+	}
+	ev = waitForState(t, c, StateConnected)
+	if ev.Attempt != 0 {
+		t.Fatalf("expected attempts to be reset after reconnecting, got %d", ev.Attempt)
+	} else if login := <-logins; login != "boxcars "+name {
+		t.Fatalf("unexpected login after reconnecting: %q", login)
+	}
+	notice := waitFor(t, c, func(ev interface{}) bool {
+		_, ok := ev.(*bgammon.EventNotice)
+		return ok
+	}).(*bgammon.EventNotice)
+	if notice.Message != "Message not sent: You are not in a match." {
+		t.Fatalf("expected chat message to be sent after reconnecting, got notice %q", notice.Message)
+	}
+
+	c.Disconnect()
+	waitForState(t, c, StateDisconnected)
+	if c.State() != StateDisconnected || c.LoggedIn() {
+		t.Fatal("expected client to be disconnected")
+	}
+}
+
+func TestRegistrationFailed(t *testing.T) {
+	server := fakeserver.NewServer()
+
+	register := func(username string) *Client {
+		c := pipeClient(server, username)
+		c.Password = "password"
+		c.Email = "player@example.com"
+		c.Register = true
+		go c.Connect()
+		return c
+	}
+
+	first := register("Player")
+	defer first.Disconnect()
+	waitForState(t, first, StateConnected)
+	if first.Register {
+		t.Error("expected Register to be reset after registering")
+	}
+
+	// The username is already taken, so the server closes the connection.
+	second := register("player")
+	defer second.Disconnect()
+	ev := waitFor(t, second, func(ev interface{}) bool {
+		s, ok := ev.(*EventConnectionState)
+		return ok && (s.State == StateDisconnected || s.State == StateBackoff || s.State == StateConnected)
+	}).(*EventConnectionState)
+	if ev.State != StateDisconnected {
+		t.Fatalf("expected registration not to be retried, got state %v", ev.State)
+	} else if !errors.Is(ev.Err, ErrRegistrationFailed) {
+		t.Fatalf("expected %v, got %v", ErrRegistrationFailed, ev.Err)
+	}
+}
+
+func TestRegistrationRetried(t *testing.T) {
+	// Nothing is listening at the address, so the connection fails before
+	// the server is able to reject the registration.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := "tcp://" + l.Addr().String()
+	l.Close()
+
+	c := NewClient(address, "player", "password")
+	c.Proxy = &url.URL{Scheme: "direct"}
+	c.Email = "player@example.com"
+	c.Register = true
+	go c.Connect()
+	defer c.Disconnect()
+
+	ev := waitFor(t, c, func(ev interface{}) bool {
+		s, ok := ev.(*EventConnectionState)
+		return ok && (s.State == StateDisconnected || s.State == StateBackoff)
+	}).(*EventConnectionState)
+	if ev.State != StateBackoff {
+		t.Fatalf("expected registration to be retried, got state %v with %v", ev.State, ev.Err)
+	} else if errors.Is(ev.Err, ErrRegistrationFailed) {
+		t.Fatalf("expected connection error, got %v", ev.Err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build js && wasm
+
+package client
+
+const (
+	DefaultServerAddress = "wss://ws.bgammon.org"
+	APPNAME              = "boxcars"
+)
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MatchOptions specifies the options used when creating a match.
+type MatchOptions struct {
+	Name     string
+	Points   int
+	Password string // Create a private match when set.
+}
+
+// ErrInvalidArgument is returned when a command argument which must be a
+// single word contains whitespace.
+var ErrInvalidArgument = errors.New("argument must not contain whitespace")
+
+// tokenArgument validates a command argument which must not contain
+// whitespace.
+func tokenArgument(arg string) (string, error) {
+	if strings.IndexFunc(arg, unicode.IsSpace) != -1 {
+		return "", ErrInvalidArgument
+	}
+	return arg, nil
+}
+
+// textArgument returns a command argument which is the final argument of a
+// command and may contain spaces. Newlines are removed, as each command is
+// terminated by a newline.
+func textArgument(arg string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(arg)
+}
+
+// Send queues a raw command to be sent to the server. Newlines are removed
+// from the command, as each command is terminated by a newline. Send never
+// blocks. Commands are kept while disconnected and are sent once the
+// connection is restored, unless they are dropped, which is reported via an
+// EventCommandFailed.
+func (c *Client) Send(command string) {
+	command = strings.TrimSpace(strings.NewReplacer("\r", " ", "\n", " ").Replace(command))
+	if command == "" {
+		return
+	}
+	if dropped := c.queue.push([]byte(command)); dropped != nil {
+		c.commandFailed(dropped, errQueueFull)
+	}
+}
+
+// List requests the list of matches.
+func (c *Client) List() {
+	c.Send("ls")
+}
+
+// Board requests the current game state.
+func (c *Client) Board() {
+	c.Send("board")
+}
+
+// Roll rolls the dice.
+func (c *Client) Roll() {
+	c.Send("roll")
+}
+
+// Move moves a checker from one space to another.
+func (c *Client) Move(from int, to int) {
+	c.Send(fmt.Sprintf("mv %d/%d", from, to))
+}
+
+// Submit confirms the moves made this turn, or accepts an offered double.
+func (c *Client) Submit() {
+	c.Send("ok")
+}
+
+// Double offers a double to the opponent.
+func (c *Client) Double() {
+	c.Send("double")
+}
+
+// Resign resigns the game, or declines an offered double.
+func (c *Client) Resign() {
+	c.Send("resign")
+}
+
+// CreateMatch creates a match. ErrInvalidArgument is returned when the
+// password contains whitespace.
+func (c *Client) CreateMatch(opts MatchOptions) error {
+	points := opts.Points
+	if points < 1 {
+		points = 1
+	}
+	password, err := tokenArgument(opts.Password)
+	if err != nil {
+		return err
+	}
+	typeAndPassword := "public"
+	if password != "" {
+		typeAndPassword = "private " + password
+	}
+	c.Send(fmt.Sprintf("c %s %d %s", typeAndPassword, points, textArgument(opts.Name)))
+	return nil
+}
+
+// Join joins a match. The password is only required for private matches.
+// ErrInvalidArgument is returned when the password contains whitespace.
+func (c *Client) Join(id int, password string) error {
+	password, err := tokenArgument(password)
+	if err != nil {
+		return err
+	}
+	command := "j " + strconv.Itoa(id)
+	if password != "" {
+		command += " " + password
+	}
+	c.Send(command)
+	return nil
+}
+
+// Watch spectates a match. When id is zero, a random match is spectated.
+func (c *Client) Watch(id int) {
+	if id == 0 {
+		c.Send("watch")
+		return
+	}
+	c.Send("watch " + strconv.Itoa(id))
+}
+
+// TV spectates random matches continuously.
+func (c *Client) TV() {
+	c.Send("tv")
+}
+
+// Leave leaves the current match.
+func (c *Client) Leave() {
+	c.Send("leave")
+}
+
+// Say sends a chat message to the opponent.
+func (c *Client) Say(message string) {
+	message = textArgument(message)
+	if strings.TrimSpace(message) == "" {
+		return
+	}
+	c.Send("say " + message)
+}
+
+// Pong responds to a ping.
+func (c *Client) Pong(message string) {
+	c.Send("pong " + textArgument(message))
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	c := NewClient("pipe", "", "")
+	send := func(f func() error) {
+		if err := f(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Roll()
+	c.Move(13, 7)
+	c.Submit()
+	c.Double()
+	c.Resign()
+	c.Watch(0)
+	c.Watch(3)
+	c.TV()
+	c.Leave()
+	c.Say("hello\nworld")
+	c.Say("  ")
+	c.Pong("1")
+	c.Send("  list \r\n")
+	c.Send("")
+	send(func() error { return c.CreateMatch(MatchOptions{Name: "My\nmatch", Points: 0}) })
+	send(func() error { return c.CreateMatch(MatchOptions{Name: "Private", Points: 5, Password: "secret"}) })
+	send(func() error { return c.Join(1, "") })
+	send(func() error { return c.Join(2, "secret") })
+
+	expected := fmt.Sprint([]string{
+		"roll", "mv 13/7", "ok", "double", "resign", "watch", "watch 3", "tv", "leave",
+		"say helloworld", "pong 1", "list",
+		"c public 1 Mymatch", "c private secret 5 Private", "j 1", "j 2 secret",
+	})
+	if commands := fmt.Sprint(queued(c.queue)); commands != expected {
+		t.Fatalf("expected %s, got %s", expected, commands)
+	}
+
+	if err := c.CreateMatch(MatchOptions{Password: "two words"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected %v, got %v", ErrInvalidArgument, err)
+	} else if err := c.Join(1, "two words"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected %v, got %v", ErrInvalidArgument, err)
+	}
+}
+
+func TestArguments(t *testing.T) {
+	for _, arg := range []string{"", "word", "p@ss!"} {
+		if token, err := tokenArgument(arg); err != nil || token != arg {
+			t.Errorf("%q: expected valid token, got %q (%v)", arg, token, err)
+		}
+	}
+	for _, arg := range []string{"two words", "tab\tseparated", "new\nline"} {
+		if _, err := tokenArgument(arg); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%q: expected %v, got %v", arg, ErrInvalidArgument, err)
+		}
+	}
+	if text := textArgument("multiple words\r\nand lines"); text != "multiple wordsand lines" {
+		t.Errorf("expected newlines to be removed, got %q", text)
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// Profile is a saved set of connection settings.
+type Profile struct {
+	Name     string `json:"name"`
+	Server   string `json:"server,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"` // Saved by earlier versions. Removed when the profile is saved.
+	Keyring  bool   `json:"keyring,omitempty"`  // Password is stored in the keyring.
+	Locale   string `json:"locale,omitempty"`
+}
+
+// Config is the configuration of the current user. It is stored in the
+// user configuration directory, or in local storage on the web.
+type Config struct {
+	Profiles    []*Profile `json:"profiles"`
+	LastProfile string     `json:"lastProfile,omitempty"`
+
+	AutoSaveMatches bool     `json:"autoSaveMatches,omitempty"` // Save matches when they end.
+	OfflineStrength Strength `json:"offlineStrength,omitempty"` // Strength of the computer opponent when playing offline.
+}
+
+// LoadConfig loads the configuration of the current user. An empty
+// configuration is returned when none has been saved.
+func LoadConfig() (*Config, error) {
+	c := &Config{}
+	buf, err := readConfig()
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return c, err
+	} else if len(buf) == 0 {
+		return c, nil
+	}
+	err = json.Unmarshal(buf, c)
+	return c, err
+}
+
+// Save saves the configuration.
+func (c *Config) Save() error {
+	buf, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeConfig(buf)
+}
+
+// Profile returns the profile with the specified name, or nil if no such
+// profile exists.
+func (c *Config) Profile(name string) *Profile {
+	for _, p := range c.Profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// SetProfile adds a profile, replacing any profile with the same name.
+func (c *Config) SetProfile(profile *Profile) {
+	for i, p := range c.Profiles {
+		if p.Name == profile.Name {
+			c.Profiles[i] = profile
+			return
+		}
+	}
+	c.Profiles = append(c.Profiles, profile)
+}
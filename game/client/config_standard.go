@@ -0,0 +1,37 @@
+//go:build !js || !wasm
+
+package client
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "boxcars", "config.json"), nil
+}
+
+func readConfig() ([]byte, error) {
+	p, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+func writeConfig(buf []byte) error {
+	p, err := configPath()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(p), 0700)
+	if err != nil {
+		return err
+	}
+	// The configuration may contain passwords.
+	return os.WriteFile(p, buf, 0600)
+}
@@ -0,0 +1,22 @@
+//go:build js && wasm
+
+package client
+
+import (
+	"syscall/js"
+)
+
+const configStorageKey = "boxcars-config"
+
+func readConfig() ([]byte, error) {
+	v := js.Global().Get("localStorage").Call("getItem", configStorageKey)
+	if v.IsNull() || v.IsUndefined() {
+		return nil, nil
+	}
+	return []byte(v.String()), nil
+}
+
+func writeConfig(buf []byte) error {
+	js.Global().Get("localStorage").Call("setItem", configStorageKey, string(buf))
+	return nil
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Dispatcher delivers events to the handlers subscribed to their type.
+// Handlers are called in the order they were subscribed, on the goroutine
+// which calls Dispatch. Handlers may subscribe and unsubscribe while an event
+// is being dispatched. Handlers subscribed while dispatching an event do not
+// receive that event, and handlers unsubscribed while dispatching an event
+// are not called after they are unsubscribed.
+type Dispatcher struct {
+	subscriptions []*Subscription
+	sync.Mutex
+}
+
+// Subscription is a handler subscribed to a Dispatcher.
+type Subscription struct {
+	d         *Dispatcher
+	eventType reflect.Type // Nil when subscribed to all events.
+	handler   func(ev interface{})
+	active    bool
+}
+
+// NewDispatcher returns a new dispatcher.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// Subscribe subscribes a handler to events of the same type as event, which
+// is typically a nil pointer such as (*bgammon.EventBoard)(nil).
+func (d *Dispatcher) Subscribe(event interface{}, handler func(ev interface{})) *Subscription {
+	return d.subscribe(reflect.TypeOf(event), handler)
+}
+
+// SubscribeAll subscribes a handler to all events.
+func (d *Dispatcher) SubscribeAll(handler func(ev interface{})) *Subscription {
+	return d.subscribe(nil, handler)
+}
+
+func (d *Dispatcher) subscribe(eventType reflect.Type, handler func(ev interface{})) *Subscription {
+	s := &Subscription{
+		d:         d,
+		eventType: eventType,
+		handler:   handler,
+		active:    true,
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	d.subscriptions = append(d.subscriptions, s)
+	return s
+}
+
+// Unsubscribe removes the subscription. Unsubscribe may be called more than
+// once.
+func (s *Subscription) Unsubscribe() {
+	d := s.d
+	d.Lock()
+	defer d.Unlock()
+
+	if !s.active {
+		return
+	}
+	s.active = false
+	for i := range d.subscriptions {
+		if d.subscriptions[i] == s {
+			d.subscriptions = append(d.subscriptions[:i:i], d.subscriptions[i+1:]...)
+			break
+		}
+	}
+}
+
+// Dispatch delivers an event to the handlers subscribed to its type and to
+// all events. Dispatch returns whether any handlers subscribed to the type of
+// the event were called.
+func (d *Dispatcher) Dispatch(ev interface{}) bool {
+	eventType := reflect.TypeOf(ev)
+
+	d.Lock()
+	subscriptions := d.subscriptions
+	d.Unlock()
+
+	var handled bool
+	for _, s := range subscriptions {
+		if s.eventType != nil && s.eventType != eventType {
+			continue
+		}
+
+		d.Lock()
+		active := s.active
+		d.Unlock()
+		if !active {
+			continue
+		}
+
+		s.handler(ev)
+		if s.eventType != nil {
+			handled = true
+		}
+	}
+	return handled
+}
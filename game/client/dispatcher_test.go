@@ -0,0 +1,66 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+)
+
+func TestDispatcher(t *testing.T) {
+	d := NewDispatcher()
+	var calls []string
+	d.Subscribe((*bgammon.EventSay)(nil), func(ev interface{}) {
+		calls = append(calls, "say: "+ev.(*bgammon.EventSay).Message)
+	})
+	d.SubscribeAll(func(ev interface{}) {
+		calls = append(calls, fmt.Sprintf("all: %T", ev))
+	})
+	notice := d.Subscribe((*bgammon.EventNotice)(nil), func(ev interface{}) {
+		calls = append(calls, "notice")
+	})
+
+	if !d.Dispatch(&bgammon.EventSay{Message: "hello"}) {
+		t.Fatal("expected event to be handled")
+	} else if !d.Dispatch(&bgammon.EventNotice{}) {
+		t.Fatal("expected event to be handled")
+	} else if d.Dispatch(&bgammon.EventWin{}) {
+		t.Fatal("expected event handled only by SubscribeAll not to be reported as handled")
+	}
+	notice.Unsubscribe()
+	notice.Unsubscribe()
+	d.Dispatch(&bgammon.EventNotice{})
+
+	expected := fmt.Sprint([]string{
+		"say: hello", "all: *bgammon.EventSay",
+		"all: *bgammon.EventNotice", "notice",
+		"all: *bgammon.EventWin",
+		"all: *bgammon.EventNotice",
+	})
+	if fmt.Sprint(calls) != expected {
+		t.Fatalf("expected %s, got %s", expected, calls)
+	}
+}
+
+func TestDispatcherSubscribeWhileDispatching(t *testing.T) {
+	d := NewDispatcher()
+	var calls []string
+	var second *Subscription
+	d.Subscribe((*bgammon.EventSay)(nil), func(ev interface{}) {
+		calls = append(calls, "first")
+		// Handlers unsubscribed while dispatching are not called, and
+		// handlers subscribed while dispatching do not receive the event.
+		second.Unsubscribe()
+		d.Subscribe((*bgammon.EventSay)(nil), func(ev interface{}) {
+			calls = append(calls, "third")
+		})
+	})
+	second = d.Subscribe((*bgammon.EventSay)(nil), func(ev interface{}) {
+		calls = append(calls, "second")
+	})
+
+	d.Dispatch(&bgammon.EventSay{})
+	if fmt.Sprint(calls) != fmt.Sprint([]string{"first"}) {
+		t.Fatalf("unexpected handlers called: %s", calls)
+	}
+}
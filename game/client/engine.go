@@ -0,0 +1,369 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+	"github.com/leonelquinteros/gotext"
+)
+
+// Strength is the playing strength of the built-in computer opponent.
+type Strength int
+
+const (
+	StrengthBeginner     Strength = iota // Makes frequent mistakes and never doubles.
+	StrengthIntermediate                 // Makes occasional mistakes.
+	StrengthExpert                       // Always plays the best move found and doubles in races.
+)
+
+// Strengths lists the available strengths from weakest to strongest.
+var Strengths = []Strength{StrengthBeginner, StrengthIntermediate, StrengthExpert}
+
+func (s Strength) String() string {
+	switch s {
+	case StrengthBeginner:
+		return gotext.Get("Beginner")
+	case StrengthIntermediate:
+		return gotext.Get("Intermediate")
+	case StrengthExpert:
+		return gotext.Get("Expert")
+	default:
+		return fmt.Sprintf("unknown (%d)", int(s))
+	}
+}
+
+// ParseStrength returns the strength with the specified English name: beginner,
+// intermediate or expert.
+func ParseStrength(name string) (Strength, error) {
+	switch strings.ToLower(name) {
+	case "beginner":
+		return StrengthBeginner, nil
+	case "intermediate":
+		return StrengthIntermediate, nil
+	case "expert":
+		return StrengthExpert, nil
+	default:
+		return 0, fmt.Errorf("unknown strength: %s", name)
+	}
+}
+
+// noise returns the standard deviation of the random error added to the
+// evaluation of each play. Weaker opponents overlook better plays more often.
+func (s Strength) noise() float64 {
+	switch s {
+	case StrengthBeginner:
+		return 4
+	case StrengthIntermediate:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// PubevalStrategy plays the moves rated highest by pubeval, the public
+// evaluation function published by Gerald Tesauro as a benchmark for
+// backgammon programs. Each way of playing the dice is evaluated and the best
+// play is returned as a whole.
+type PubevalStrategy struct {
+	Strength Strength
+
+	rand *rand.Rand
+}
+
+// NewPubevalStrategy returns a strategy which plays at the specified strength.
+func NewPubevalStrategy(strength Strength) *PubevalStrategy {
+	return &PubevalStrategy{
+		Strength: strength,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *PubevalStrategy) Decide(state *bgammon.GameState) (Decision, [][]int) {
+	player := state.PlayerNumber
+	if state.DoubleOffered {
+		if state.Turn == player {
+			// Wait for the opponent to respond to our double.
+			return DecisionWait, nil
+		} else if s.takes(state.Board, player) {
+			return DecisionAccept, nil
+		}
+		return DecisionResign, nil
+	} else if state.MayRoll() {
+		if s.doubles(state, player) {
+			return DecisionDouble, nil
+		}
+		return DecisionRoll, nil
+	} else if len(state.Available) == 0 {
+		return DecisionWait, nil
+	}
+
+	plays := candidatePlays(state.Game)
+	if len(plays) == 0 {
+		return DecisionMove, [][]int{state.Available[0]}
+	}
+	best, bestScore := 0, 0.0
+	for i, play := range plays {
+		score := pubeval(pubevalPosition(play.board, player))
+		if noise := s.Strength.noise(); noise > 0 {
+			score += s.rand.NormFloat64() * noise
+		}
+		if i == 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return DecisionMove, plays[best].moves
+}
+
+// play is a sequence of moves made during a turn.
+type play struct {
+	moves [][]int
+	board []int // The board after the moves are made.
+}
+
+// candidatePlays returns every way the player whose turn it is may finish
+// moving. The legal moves are determined by the rules implemented by bgammon,
+// and a play continues until no legal move remains, as the turn may not end
+// before then. Plays which result in the same board are only returned once.
+// The game, and the moves and boards returned, are in the local perspective
+// of the player, as sent by the server.
+func candidatePlays(g *bgammon.Game) []play {
+	var plays []play
+	seen, played := make(map[string]bool), make(map[string]bool)
+	var search func(g *bgammon.Game, moves [][]int)
+	search = func(g *bgammon.Game, moves [][]int) {
+		// When doubles are rolled, the remaining dice depend only on the
+		// number of moves made.
+		key := fmt.Sprint(g.Board, g.Moves)
+		if g.Roll1 == g.Roll2 {
+			key = fmt.Sprint(g.Board, len(g.Moves))
+		}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		legal := g.LegalMoves(true)
+		if len(legal) == 0 {
+			if key := fmt.Sprint(g.Board); !played[key] {
+				played[key] = true
+				plays = append(plays, play{moves: moves, board: g.Board})
+			}
+			return
+		}
+		for _, move := range legal {
+			next := g.Copy()
+			if ok, _ := next.AddMoves([][]int{move}, true); !ok {
+				continue
+			}
+			search(next, append(moves[:len(moves):len(moves)], move))
+		}
+	}
+	search(g.Copy(), nil)
+	return plays
+}
+
+// takes returns whether an offered double should be accepted. Doubles are
+// always accepted while the checkers of both players are in contact.
+func (s *PubevalStrategy) takes(board []int, player int) bool {
+	pos := pubevalPosition(board, player)
+	if s.Strength == StrengthBeginner || !pubevalRace(pos) {
+		return true
+	}
+	own, opp := pubevalPips(pos)
+	return float64(own) <= float64(opp)*1.12
+}
+
+// doubles returns whether a double should be offered before rolling. Only
+// expert opponents double, and only when leading a race by a sufficient
+// margin.
+func (s *PubevalStrategy) doubles(state *bgammon.GameState, player int) bool {
+	if s.Strength != StrengthExpert || state.Points == 1 || state.Turn != player || state.Winner != 0 || (state.DoublePlayer != 0 && state.DoublePlayer != player) {
+		return false
+	}
+	pos := pubevalPosition(state.Board, player)
+	if !pubevalRace(pos) {
+		return false
+	}
+	own, opp := pubevalPips(pos)
+	return own > 0 && float64(opp) >= float64(own)*1.08
+}
+
+// pubevalPosition returns a board from the perspective of a player as
+// expected by pubeval. The board must be in the local perspective of the
+// player, as sent by the server, where the home of the player is
+// bgammon.SpaceHomePlayer regardless of the player number. Spaces 1 to 24 are
+// numbered towards the home of the player, whose checkers are positive, while
+// opponent checkers are negative. Space 25 holds the checkers of the player on
+// the bar, space 0 the checkers of the opponent on the bar, space 26 the
+// checkers the player has borne off and space 27 the checkers the opponent has
+// borne off.
+func pubevalPosition(board []int, player int) [28]int {
+	var pos [28]int
+	for point := 1; point <= 24; point++ {
+		pos[point] = board[point]
+		if player == 2 {
+			pos[point] = -pos[point]
+		}
+	}
+	opp := opponent(player)
+	pos[25] = playerCheckers(board, bgammon.SpaceBarPlayer, player)
+	pos[0] = -playerCheckers(board, bgammon.SpaceBarOpponent, opp)
+	pos[26] = playerCheckers(board, bgammon.SpaceHomePlayer, player)
+	pos[27] = -playerCheckers(board, bgammon.SpaceHomeOpponent, opp)
+	return pos
+}
+
+// pubevalRace returns whether the checkers of both players have passed each
+// other, so that no further checkers may be hit.
+func pubevalRace(pos [28]int) bool {
+	if pos[25] > 0 || pos[0] < 0 {
+		return false
+	}
+	var furthest int
+	for point := 24; point >= 1; point-- {
+		if pos[point] > 0 {
+			furthest = point
+			break
+		}
+	}
+	for point := 1; point <= 24; point++ {
+		if pos[point] < 0 {
+			return point > furthest
+		}
+	}
+	return true
+}
+
+// pubevalPips returns the pip counts of the player and the opponent.
+func pubevalPips(pos [28]int) (own int, opp int) {
+	for point := 1; point <= 24; point++ {
+		if pos[point] > 0 {
+			own += pos[point] * point
+		} else {
+			opp -= pos[point] * (25 - point)
+		}
+	}
+	own += pos[25] * 25
+	opp -= pos[0] * 25
+	return own, opp
+}
+
+// pubeval returns the score of a position from the perspective of the player.
+// Higher scores are better.
+func pubeval(pos [28]int) float64 {
+	if pos[26] == checkersPerPlayer {
+		return 99999999
+	}
+
+	var x [122]float64
+	for j := 1; j <= 24; j++ {
+		i := 5 * (j - 1)
+		switch n := pos[25-j]; {
+		case n == -1:
+			x[i] = 1
+		case n == 1:
+			x[i+1] = 1
+		case n >= 2:
+			x[i+2] = 1
+			if n == 3 {
+				x[i+3] = 1
+			} else if n >= 4 {
+				x[i+4] = float64(n-3) / 2
+			}
+		}
+	}
+	x[120] = -float64(pos[0]) / 2
+	x[121] = float64(pos[26]) / checkersPerPlayer
+
+	weights := &pubevalContactWeights
+	if pubevalRace(pos) {
+		weights = &pubevalRaceWeights
+	}
+	var score float64
+	for i, v := range x {
+		score += weights[i] * v
+	}
+	return score
+}
+
+const checkersPerPlayer = 15
+
+// playerCheckers returns the number of checkers of the player on a space.
+func playerCheckers(board []int, space int, player int) int {
+	v := board[space]
+	if player == 2 {
+		v = -v
+	}
+	if v < 0 {
+		return 0
+	}
+	return v
+}
+
+func opponent(player int) int {
+	if player == 1 {
+		return 2
+	}
+	return 1
+}
+
+// Weights of pubeval for race and contact positions.
+var (
+	pubevalRaceWeights = [122]float64{
+		0, -.17160, .27010, .29906, -.08471,
+		0, -1.40375, -1.05121, .07217, -.01351,
+		0, -1.29506, -2.16183, .13246, -1.03508,
+		0, -2.29847, -2.34631, .17253, .08302,
+		0, -1.27266, -2.87401, -.07456, -.34240,
+		0, -1.34640, -2.46556, -.13022, -.01591,
+		0, .27448, .60015, .48302, .25236,
+		0, .39521, .68178, .05281, .09266,
+		0, .24855, -.06844, -.37646, .05685,
+		0, .17405, .00430, .74427, .00576,
+		0, .12392, .31202, -.91035, -.16270,
+		0, .01418, -.10839, -.02781, -.88035,
+		0, 1.07274, 2.00366, 1.16242, .22520,
+		0, .85631, 1.06349, 1.49549, .18966,
+		0, .37183, -.50352, -.14818, .12039,
+		0, .13681, .13978, 1.11245, -.12707,
+		0, -.22082, .20178, -.06285, -.52728,
+		0, -.13597, -.19412, -.09308, -1.26062,
+		0, 3.05454, 5.16874, 1.50680, 5.35000,
+		0, 2.19605, 3.85390, .88296, 2.30052,
+		0, .92321, 1.08744, -.11696, -.78560,
+		0, -.09795, -.83050, -1.09167, -4.94251,
+		0, -1.00316, -3.66465, -2.56906, -9.67677,
+		0, -2.77982, -7.26713, -3.40177, -12.32252,
+		0, 3.42040,
+	}
+	pubevalContactWeights = [122]float64{
+		.25696, -.66937, -1.66135, -2.02487, -2.53398,
+		-.16092, -1.11725, -1.06654, -.92830, -1.99558,
+		-1.10388, -.80802, .09856, -.62086, -1.27999,
+		-.59220, -.73667, .89032, -.38933, -1.59847,
+		-1.50197, -.60966, 1.56166, -.47389, -1.80390,
+		-.83425, -.97741, -1.41371, .24500, .10970,
+		-1.36476, -1.05572, 1.15420, .11069, -.38319,
+		-.74816, -.59244, .81116, -.39511, .11424,
+		-.73169, -.56074, 1.09792, .15977, .13786,
+		-1.18435, -.43363, 1.06169, -.21329, .04798,
+		-.94373, -.22982, 1.22737, -.13099, -.06295,
+		-.75882, -.13658, 1.78389, .30416, .36797,
+		-.69851, .13003, 1.23070, .40868, -.21081,
+		-.64073, .31061, 1.59554, .65718, .25429,
+		-.80789, .08240, 1.78964, .54304, .41174,
+		-1.06161, .07851, 2.01451, .49786, .91936,
+		-.90750, .05941, 1.83120, .58722, 1.28777,
+		-.83711, -.33248, 2.64983, .52698, .82132,
+		-.58897, -1.18223, 3.35809, .62017, .57353,
+		-.07276, -.36214, 4.37655, .45481, .21746,
+		.10504, -.61977, 3.54001, .04612, -.18108,
+		.63211, -.87046, 2.47673, -.48016, -1.27157,
+		.86505, -1.11342, 1.24612, -.82385, -2.77082,
+		1.23606, -1.59529, .10438, -1.30206, -4.11520,
+		5.62596, -2.75800,
+	}
+)
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"testing"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+)
+
+// TestPubevalBearOff checks that both players bear off their last checkers.
+// The board is sent by the server from the side of each player.
+func TestPubevalBearOff(t *testing.T) {
+	for _, player := range []int{1, 2} {
+		sign := 1
+		if player == 2 {
+			sign = -1
+		}
+		board := make([]int, bgammon.BoardSpaces)
+		board[1] = 2 * sign
+		board[bgammon.SpaceHomePlayer] = 13 * sign
+		board[24] = -15 * sign
+
+		state := &bgammon.GameState{
+			Game: &bgammon.Game{
+				Board: board,
+				Turn:  player,
+				Roll1: 1,
+				Roll2: 2,
+			},
+			PlayerNumber: player,
+		}
+		state.Available = state.LegalMoves(true)
+
+		decision, moves := NewPubevalStrategy(StrengthExpert).Decide(state)
+		if decision != DecisionMove {
+			t.Fatalf("player %d: expected to move, got decision %v", player, decision)
+		}
+		if ok, _ := state.AddMoves(moves, true); !ok {
+			t.Fatalf("player %d: illegal moves %v", player, moves)
+		}
+		if state.Board[bgammon.SpaceHomePlayer] != 15*sign {
+			t.Fatalf("player %d: expected to bear off the last checkers, moved %v", player, moves)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+	"github.com/leonelquinteros/gotext"
+)
+
+// headless renders the lobby, board, dice and chat as text while running in
+// headless mode.
+type headless struct {
+	out     io.Writer
+	client  *Client
+	options HeadlessOptions
+
+	lastLobby string
+	lastBoard string
+
+	matchID       int    // ID of the match being played.
+	matchPassword string // Password of the match being played.
+	reconnecting  bool
+
+	sync.Mutex
+}
+
+func (h *headless) write(s string) {
+	h.Lock()
+	defer h.Unlock()
+	fmt.Fprintln(h.out, s)
+}
+
+// drawLobby prints the list of matches when it has changed.
+func (h *headless) drawLobby(games []bgammon.GameListing) {
+	var b strings.Builder
+	b.WriteString(gotext.Get("Matches") + "\n")
+	if len(games) == 0 {
+		b.WriteString("  " + gotext.Get("No matches.") + "\n")
+	}
+	for _, g := range games {
+		private := ""
+		if g.Password {
+			private = "*"
+		}
+		fmt.Fprintf(&b, "  %5d%-1s  %d/2  %3d  %s\n", g.ID, private, g.Players, g.Points, g.Name)
+	}
+	b.WriteString(gotext.Get("Type %s to join a match or %s to create a match.", "/j <id> [password]", "/c public <points> <name>"))
+
+	s := b.String()
+	h.Lock()
+	defer h.Unlock()
+	if s == h.lastLobby {
+		return
+	}
+	h.lastLobby = s
+	h.lastBoard = ""
+	fmt.Fprintln(h.out, s)
+}
+
+// drawBoard prints the board when it has changed.
+func (h *headless) drawBoard(gs *bgammon.GameState) {
+	s := formatBoard(gs)
+	h.Lock()
+	defer h.Unlock()
+	if s == h.lastBoard {
+		return
+	}
+	h.lastBoard = s
+	h.lastLobby = ""
+	fmt.Fprintln(h.out, s)
+}
+
+// formatBoard returns a text representation of the board, dice and players.
+// Checkers of player 1 are drawn as X and checkers of player 2 as O. Stacks of
+// more than five checkers are labeled with their size.
+func formatBoard(gs *bgammon.GameState) string {
+	const rows = 5
+	if len(gs.Board) < bgammon.BoardSpaces {
+		return ""
+	}
+
+	checker := func(space int) string {
+		if gs.Board[space] > 0 {
+			return "X"
+		} else if gs.Board[space] < 0 {
+			return "O"
+		}
+		return " "
+	}
+	count := func(space int) int {
+		v := gs.Board[space]
+		if v < 0 {
+			return -v
+		}
+		return v
+	}
+	cell := func(space int, row int) string {
+		c := count(space)
+		if row == rows-1 && c > rows {
+			return fmt.Sprintf("%3d", c)
+		} else if c > row {
+			return "  " + checker(space)
+		}
+		return "  ."
+	}
+	half := func(b *strings.Builder, spaces []int, row int) {
+		for i, space := range spaces {
+			if i == 6 {
+				b.WriteString(" |")
+			}
+			b.WriteString(cell(space, row))
+		}
+	}
+	label := func(b *strings.Builder, spaces []int) {
+		for i, space := range spaces {
+			if i == 6 {
+				b.WriteString(" |")
+			}
+			fmt.Fprintf(b, "%3d", space)
+		}
+		b.WriteString("\n")
+	}
+
+	top := []int{13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24}
+	bottom := []int{12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+
+	var b strings.Builder
+	label(&b, top)
+	for row := 0; row < rows; row++ {
+		half(&b, top, row)
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "  BAR X:%d O:%d  OFF X:%d O:%d\n", count(bgammon.SpaceBarPlayer), count(bgammon.SpaceBarOpponent), count(bgammon.SpaceHomePlayer), count(bgammon.SpaceHomeOpponent))
+	for row := rows - 1; row >= 0; row-- {
+		half(&b, bottom, row)
+		b.WriteString("\n")
+	}
+	label(&b, bottom)
+
+	player := func(p bgammon.Player, symbol string) {
+		name := p.Name
+		if name == "" {
+			name = gotext.Get("Waiting...")
+		}
+		turn := " "
+		if gs.Turn != 0 && gs.Turn == p.Number {
+			turn = ">"
+		}
+		fmt.Fprintf(&b, "%s %s %s  %d/%d  %s: %d\n", turn, symbol, name, p.Points, gs.Points, gotext.Get("Pips"), gs.Pips(p.Number))
+	}
+	player(gs.Player1, "X")
+	player(gs.Player2, "O")
+
+	if gs.Roll1 != 0 || gs.Roll2 != 0 {
+		fmt.Fprintf(&b, "%s: %d-%d", gotext.Get("Dice"), gs.Roll1, gs.Roll2)
+		if gs.DoubleValue > 1 {
+			fmt.Fprintf(&b, "  %s: %d", gotext.Get("Cube"), gs.DoubleValue)
+		}
+		b.WriteString("\n")
+	}
+
+	if !gs.Spectating {
+		var hints []string
+		if gs.MayRoll() {
+			hints = append(hints, "/roll")
+		}
+		if gs.MayDouble() {
+			hints = append(hints, "/double")
+		}
+		if len(gs.Available) > 0 {
+			hints = append(hints, "/mv <from>/<to>")
+		}
+		if gs.MayOK() {
+			hints = append(hints, "/ok")
+		}
+		if len(hints) > 0 {
+			b.WriteString(gotext.Get("Commands: %s", strings.Join(hints, " ")) + "\n")
+		}
+		if len(gs.Available) > 0 {
+			b.WriteString(gotext.Get("Legal moves: %s", bgammon.FormatMoves(gs.Available)) + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// HeadlessOptions configures what is shown while running in headless mode.
+type HeadlessOptions struct {
+	Watch bool // Watch a random match.
+	TV    bool // Watch random matches continuously.
+}
+
+// RunHeadless connects to the server and renders the lobby, board, dice and
+// chat as text instead of drawing them on screen. Lines read from in are sent
+// to the server as chat messages, or as commands when prefixed with a slash.
+// RunHeadless returns after /quit is entered or in is closed.
+func RunHeadless(c *Client, options HeadlessOptions, in io.Reader, out io.Writer) error {
+	h := &headless{
+		out:     out,
+		client:  c,
+		options: options,
+	}
+	go h.handleEvents()
+	go c.Connect()
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		} else if text == "/quit" || text == "/exit" {
+			break
+		}
+		h.handleInput(text)
+	}
+
+	c.Disconnect()
+	return scanner.Err()
+}
+
+// handleInput sends a line entered by the user to the server.
+func (h *headless) handleInput(text string) {
+	c := h.client
+	if text[0] != '/' {
+		h.write(fmt.Sprintf("<%s> %s", c.PlayerName(), text))
+		c.Say(text)
+		return
+	}
+
+	fields := strings.Fields(text[1:])
+	if len(fields) == 0 {
+		return
+	}
+	switch strings.ToLower(fields[0]) {
+	case "rawevents":
+		h.showRawEvents()
+		return
+	case "j", "join":
+		// Remember the password so that the match may be rejoined after
+		// reconnecting.
+		h.Lock()
+		h.matchPassword = ""
+		if len(fields) > 2 {
+			h.matchPassword = fields[2]
+		}
+		h.Unlock()
+	}
+	c.Send(text[1:])
+}
+
+// handleEvents prints the events received by the client.
+func (h *headless) handleEvents() {
+	c := h.client
+	for e := range c.Events {
+		switch ev := e.(type) {
+		case *bgammon.EventWelcome:
+			h.write(fmt.Sprintf("*** Welcome, %s.", ev.PlayerName))
+		case *bgammon.EventHelp:
+			h.write(fmt.Sprintf("*** Help: %s", ev.Message))
+		case *bgammon.EventNotice:
+			h.write(fmt.Sprintf("*** %s", ev.Message))
+		case *bgammon.EventSay:
+			h.write(fmt.Sprintf("<%s> %s", ev.Player, ev.Message))
+		case *bgammon.EventList:
+			h.drawLobby(ev.Games)
+		case *bgammon.EventBoard:
+			h.drawBoard(&ev.GameState)
+		case *bgammon.EventJoined:
+			if ev.Player == c.PlayerName() {
+				h.Lock()
+				h.matchID = ev.GameID
+				h.Unlock()
+			} else {
+				h.write("*** " + gotext.Get("%s joined the match.", ev.Player))
+			}
+		case *bgammon.EventLeft:
+			if ev.Player == c.PlayerName() {
+				h.Lock()
+				h.matchID = 0
+				h.matchPassword = ""
+				h.Unlock()
+				c.List()
+			} else {
+				h.write("*** " + gotext.Get("%s left the match.", ev.Player))
+			}
+		case *bgammon.EventFailedJoin:
+			h.write("*** " + gotext.Get("Failed to join match: %s", ev.Reason))
+		case *bgammon.EventFailedLeave:
+			h.write("*** " + gotext.Get("Failed to leave match: %s", ev.Reason))
+		case *bgammon.EventFailedRoll:
+			h.write(fmt.Sprintf("*** Failed to roll: %s", ev.Reason))
+		case *bgammon.EventFailedMove:
+			var extra string
+			if ev.From != 0 || ev.To != 0 {
+				extra = fmt.Sprintf(" from %s to %s", bgammon.FormatSpace(ev.From), bgammon.FormatSpace(ev.To))
+			}
+			h.write("*** " + gotext.Get("Failed to move checker%s: %s", extra, ev.Reason))
+			c.Board()
+		case *bgammon.EventFailedOk:
+			h.write("*** " + gotext.Get("Failed to submit moves: %s", ev.Reason))
+			c.Board()
+		case *bgammon.EventRolled:
+			dice := fmt.Sprintf("%d-%d", ev.Roll1, ev.Roll2)
+			if ev.Roll1 == 0 || ev.Roll2 == 0 {
+				dice = fmt.Sprintf("%d", ev.Roll1+ev.Roll2)
+			}
+			h.write("*** " + gotext.Get("%s rolled %s.", ev.Player, dice))
+		case *bgammon.EventMoved:
+			h.write("*** " + gotext.Get("%s moved %s.", ev.Player, bgammon.FormatMoves(ev.Moves)))
+		case *bgammon.EventWin:
+			h.write("*** " + gotext.Get("%s wins!", ev.Player))
+		case *bgammon.EventPing:
+			c.Pong(ev.Message)
+		case *EventConnectionState:
+			h.handleConnectionState(ev)
+		case *EventCapabilities:
+			h.startWatching()
+		case *EventCommandFailed:
+			h.write("*** " + gotext.Get("Failed to send command %s: %s", ev.Command, ev.Err))
+		case *EventLatencyWarning:
+			h.write("*** " + gotext.Get("Warning: The connection to the server is slow (%s, usually %s). Moves may be delayed.", FormatLatency(ev.Latency), FormatLatency(ev.Average)))
+		}
+	}
+}
+
+func (h *headless) handleConnectionState(ev *EventConnectionState) {
+	switch ev.State {
+	case StateConnected:
+		h.Lock()
+		reconnecting := h.reconnecting
+		h.reconnecting = false
+		matchID, password := h.matchID, h.matchPassword
+		h.Unlock()
+		if !reconnecting {
+			return
+		}
+		h.write("*** " + gotext.Get("Reconnected."))
+		if matchID != 0 {
+			h.write("*** " + gotext.Get("Rejoining match..."))
+			h.client.Join(matchID, password)
+		}
+	case StateBackoff:
+		var proxyErr *ProxyError
+		if IsCertificateError(ev.Err) {
+			h.write("*** " + gotext.Get("Failed to verify the certificate of the server: %s", ev.Err))
+		} else if errors.As(ev.Err, &proxyErr) {
+			h.write("*** " + gotext.Get("Failed to connect via proxy: %s", proxyErr))
+		}
+		h.Lock()
+		reconnecting := h.reconnecting
+		h.reconnecting = true
+		h.Unlock()
+		if !reconnecting {
+			h.write("*** " + gotext.Get("Connection lost."))
+		}
+		delay := time.Until(ev.Retry).Round(time.Second)
+		h.write("*** " + gotext.Get("Reconnecting in %s...", delay))
+	case StateDisconnected:
+		if ev.Err != nil {
+			h.write(fmt.Sprintf("*** %s", ev.Err))
+		}
+		h.write("*** " + gotext.Get("Disconnected."))
+	}
+}
+
+// startWatching watches matches as requested via HeadlessOptions once the
+// capabilities of the server are known. Random matches are watched again
+// after reconnecting.
+func (h *headless) startWatching() {
+	c := h.client
+	h.Lock()
+	watch := h.options.Watch
+	h.options.Watch = false
+	h.Unlock()
+
+	if h.options.TV {
+		if !c.HasCapability(CapabilityTV) {
+			h.write("*** " + gotext.Get("The server does not support watching matches continuously."))
+			return
+		}
+		c.TV()
+	} else if watch {
+		if !c.HasCapability(CapabilityWatch) {
+			h.write("*** " + gotext.Get("The server does not support watching matches."))
+			return
+		}
+		c.Watch(0)
+	}
+}
+
+// showRawEvents prints the messages received from the server which could not
+// be decoded.
+func (h *headless) showRawEvents() {
+	events := h.client.RawEvents()
+	if len(events) == 0 {
+		h.write("*** " + gotext.Get("No unsupported events have been received."))
+		return
+	}
+	h.write("*** " + gotext.Get("Unsupported events:"))
+	for _, ev := range events {
+		h.write(fmt.Sprintf("*** %s %s (%s)", ev.Time.Format("15:04:05"), ev.Data, ev.Err))
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+	"code.rocket9labs.com/tslocum/boxcars/game/fakeserver"
+)
+
+// lockedBuffer is a buffer which may be written to and read from
+// concurrently.
+type lockedBuffer struct {
+	buf bytes.Buffer
+	sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
+
+// eventually polls until condition returns true.
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	timeout := time.Now().Add(testTimeout)
+	for !condition() {
+		if time.Now().After(timeout) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestHeadlessRejoin tests that the match being played is rejoined after
+// reconnecting in headless mode.
+func TestHeadlessRejoin(t *testing.T) {
+	server := fakeserver.NewServer()
+	conns := make(chan *PipeTransport, 2)
+	a := reconnectingClient(server, "Alice", conns)
+
+	in, input := io.Pipe()
+	out := &lockedBuffer{}
+	done := make(chan error, 1)
+	go func() {
+		done <- RunHeadless(a, HeadlessOptions{}, in, out)
+	}()
+	defer input.Close()
+
+	b := pipeClient(server, "Bob")
+	go b.Connect()
+	defer b.Disconnect()
+	waitForState(t, b, StateConnected)
+
+	eventually(t, a.Connected)
+	io.WriteString(input, "/c public 1 Rejoined match\n")
+	var matchID int
+	eventually(t, func() bool {
+		b.List()
+		list := waitFor(t, b, func(ev interface{}) bool {
+			_, ok := ev.(*bgammon.EventList)
+			return ok
+		}).(*bgammon.EventList)
+		if len(list.Games) == 0 {
+			return false
+		}
+		matchID = list.Games[0].ID
+		return true
+	})
+	if err := b.Join(matchID, ""); err != nil {
+		t.Fatal(err)
+	}
+	waitForBoard(t, b, func(state *bgammon.GameState) bool {
+		return state.Player1.Name == "Alice" && state.Player2.Name == "Bob"
+	})
+
+	(<-conns).Close()
+	waitFor(t, b, func(ev interface{}) bool {
+		left, ok := ev.(*bgammon.EventLeft)
+		return ok && left.Player == "Alice"
+	})
+	eventually(t, func() bool {
+		return a.State() == StateBackoff
+	})
+	a.Retry()
+
+	joined := waitFor(t, b, func(ev interface{}) bool {
+		joined, ok := ev.(*bgammon.EventJoined)
+		return ok && joined.Player == "Alice"
+	}).(*bgammon.EventJoined)
+	if joined.GameID != matchID {
+		t.Fatalf("expected match %d to be rejoined, joined %d", matchID, joined.GameID)
+	} else if output := out.String(); !strings.Contains(output, "Rejoining match...") {
+		t.Fatalf("expected rejoining to be shown, got:\n%s", output)
+	}
+
+	input.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for headless mode to exit")
+	}
+}
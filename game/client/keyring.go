@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+	"log"
+)
+
+// keyringService is the service name of passwords stored in the keyring.
+const keyringService = "boxcars"
+
+// ErrKeyringUnavailable is returned when passwords may not be saved because
+// no keyring is available.
+var ErrKeyringUnavailable = errors.New("keyring unavailable")
+
+// keyringAccount returns the name under which the password of a profile is
+// stored in the keyring.
+func (p *Profile) keyringAccount() string {
+	server := p.Server
+	if server == "" {
+		server = DefaultServerAddress
+	}
+	return p.Username + "@" + server
+}
+
+// SetPassword saves the password of the profile in the keyring of the
+// operating system. Passwords are never saved in the configuration file:
+// when no keyring is available, ErrKeyringUnavailable is returned and the
+// password is not saved. When password is empty, any saved password is
+// removed.
+func (p *Profile) SetPassword(password string) error {
+	if p.Keyring {
+		err := keyringDelete(keyringService, p.keyringAccount())
+		if err != nil {
+			log.Printf("warning: failed to remove password from keyring: %s", err)
+		}
+		p.Keyring = false
+	}
+	p.Password = ""
+	if password == "" {
+		return nil
+	}
+
+	err := keyringSet(keyringService, p.keyringAccount(), password)
+	if err != nil {
+		return err
+	}
+	p.Keyring = true
+	return nil
+}
+
+// GetPassword returns the saved password of the profile, or an empty string
+// when no password is saved. Passwords saved in the configuration file by
+// earlier versions are still returned until the profile is saved again.
+func (p *Profile) GetPassword() string {
+	if !p.Keyring {
+		return p.Password
+	}
+	password, err := keyringGet(keyringService, p.keyringAccount())
+	if err != nil {
+		log.Printf("warning: failed to read password from keyring: %s", err)
+		return ""
+	}
+	return password
+}
@@ -0,0 +1,77 @@
+//go:build !js || !wasm
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// The keyring is accessed using the security command on macOS and the
+// secret-tool command (libsecret) on Linux. Passwords are passed to these
+// commands via standard input so that they are not visible to other users.
+
+func keyringCommand(name string, stdin string, args ...string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", ErrKeyringUnavailable
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	err = cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// securityQuote quotes an argument of a command run by security -i.
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func keyringSet(service string, account string, password string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		_, err := keyringCommand("security", fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", securityQuote(service), securityQuote(account), securityQuote(password)), "-i")
+		return err
+	case "linux", "freebsd", "openbsd", "netbsd":
+		_, err := keyringCommand("secret-tool", password, "store", "--label="+service+" ("+account+")", "service", service, "account", account)
+		return err
+	default:
+		return ErrKeyringUnavailable
+	}
+}
+
+func keyringGet(service string, account string) (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		password, err := keyringCommand("security", "", "find-generic-password", "-s", service, "-a", account, "-w")
+		return strings.TrimSuffix(password, "\n"), err
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return keyringCommand("secret-tool", "", "lookup", "service", service, "account", account)
+	default:
+		return "", ErrKeyringUnavailable
+	}
+}
+
+func keyringDelete(service string, account string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		_, err := keyringCommand("security", "", "delete-generic-password", "-s", service, "-a", account)
+		return err
+	case "linux", "freebsd", "openbsd", "netbsd":
+		_, err := keyringCommand("secret-tool", "", "clear", "service", service, "account", account)
+		return err
+	default:
+		return ErrKeyringUnavailable
+	}
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSecurityQuote(t *testing.T) {
+	testCases := []struct {
+		arg      string
+		expected string
+	}{
+		{"boxcars", `"boxcars"`},
+		{`pass word"`, `"pass word\""`},
+		{`back\slash`, `"back\\slash"`},
+	}
+	for _, tc := range testCases {
+		if quoted := securityQuote(tc.arg); quoted != tc.expected {
+			t.Errorf("%q: expected %s, got %s", tc.arg, tc.expected, quoted)
+		}
+	}
+}
+
+// TestPasswordWithoutKeyring tests that passwords are not saved in the
+// configuration file when no keyring is available.
+func TestPasswordWithoutKeyring(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	p := &Profile{Name: "test", Username: "player", Password: "legacy"}
+	if password := p.GetPassword(); password != "legacy" {
+		t.Fatalf("expected password saved by an earlier version, got %q", password)
+	}
+	if err := p.SetPassword("secret"); !errors.Is(err, ErrKeyringUnavailable) {
+		t.Fatalf("expected %v, got %v", ErrKeyringUnavailable, err)
+	} else if p.Password != "" || p.Keyring {
+		t.Fatalf("expected password not to be saved, got %+v", p)
+	} else if password := p.GetPassword(); password != "" {
+		t.Fatalf("expected no password, got %q", password)
+	}
+}
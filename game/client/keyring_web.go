@@ -0,0 +1,15 @@
+//go:build js && wasm
+
+package client
+
+func keyringSet(service string, account string, password string) error {
+	return ErrKeyringUnavailable
+}
+
+func keyringGet(service string, account string) (string, error) {
+	return "", ErrKeyringUnavailable
+}
+
+func keyringDelete(service string, account string) error {
+	return ErrKeyringUnavailable
+}
@@ -0,0 +1,269 @@
+package client
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ConnectionQuality is an estimate of the quality of the connection to the
+// server based on the measured round-trip time.
+type ConnectionQuality int
+
+const (
+	QualityUnknown ConnectionQuality = iota
+	QualityGood
+	QualityFair
+	QualityPoor
+)
+
+func (q ConnectionQuality) String() string {
+	switch q {
+	case QualityGood:
+		return "good"
+	case QualityFair:
+		return "fair"
+	case QualityPoor:
+		return "poor"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	latencySamples = 10 // Number of measurements in the rolling average.
+
+	// A measurement is a spike when it is more than latencySpikeFactor times
+	// the average and longer than latencySpikeMin.
+	latencySpikeFactor = 3
+	latencySpikeMin    = 500 * time.Millisecond
+
+	// latencyWarningInterval is the minimum interval between warnings.
+	latencyWarningInterval = time.Minute
+)
+
+// EventLatencyWarning is sent via Client.Events when the round-trip time to
+// the server spikes.
+type EventLatencyWarning struct {
+	Latency time.Duration // Measured round-trip time.
+	Average time.Duration // Average round-trip time before the spike.
+}
+
+// latencyStats is a rolling window of round-trip time measurements. It is
+// guarded by Client.stateLock.
+type latencyStats struct {
+	samples [latencySamples]time.Duration
+	count   int
+	next    int
+	warned  time.Time
+
+	ping     int       // Number of the last ping sent.
+	pingSent time.Time // Time the pending ping was sent, or zero when no ping is pending.
+
+	lists []listRequest // Pending list requests, oldest first, while pings are not echoed.
+}
+
+// listRequest is a list request sent to measure the round-trip time to
+// servers which do not echo pings.
+type listRequest struct {
+	sent  time.Time
+	probe bool // Sent by keepAlive, so the response is not passed on.
+}
+
+func (s *latencyStats) add(d time.Duration) {
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % latencySamples
+	if s.count < latencySamples {
+		s.count++
+	}
+}
+
+// average returns the average round-trip time and the jitter, which is the
+// average difference between consecutive measurements.
+func (s *latencyStats) average() (time.Duration, time.Duration) {
+	if s.count == 0 {
+		return 0, 0
+	}
+	first := (s.next - s.count + latencySamples) % latencySamples
+	var total, jitter time.Duration
+	for i := 0; i < s.count; i++ {
+		d := s.samples[(first+i)%latencySamples]
+		total += d
+		if i > 0 {
+			diff := d - s.samples[(first+i-1)%latencySamples]
+			if diff < 0 {
+				diff = -diff
+			}
+			jitter += diff
+		}
+	}
+	average := total / time.Duration(s.count)
+	if s.count > 1 {
+		jitter /= time.Duration(s.count - 1)
+	}
+	return average, jitter
+}
+
+// addLatency records a round-trip time measurement. A warning is sent via
+// Client.Events when the measurement is a spike.
+func (c *Client) addLatency(d time.Duration) {
+	atomic.StoreInt64(&c.metrics.latency, int64(d))
+
+	c.stateLock.Lock()
+	average, _ := c.latency.average()
+	spike := c.latency.count >= 3 && d > latencySpikeMin && d > average*latencySpikeFactor && time.Since(c.latency.warned) >= latencyWarningInterval
+	if spike {
+		c.latency.warned = time.Now()
+	}
+	c.latency.add(d)
+	c.stateLock.Unlock()
+
+	if spike {
+		c.sendEvent(&EventLatencyWarning{
+			Latency: d,
+			Average: average,
+		})
+	}
+}
+
+// Latency returns the average round-trip time to the server and the jitter.
+// Zero is returned until the round-trip time has been measured.
+func (c *Client) Latency() (average time.Duration, jitter time.Duration) {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	return c.latency.average()
+}
+
+// Quality returns an estimate of the quality of the connection to the server.
+func (c *Client) Quality() ConnectionQuality {
+	if !c.Connected() {
+		return QualityUnknown
+	}
+	average, jitter := c.Latency()
+	switch {
+	case average == 0:
+		return QualityUnknown
+	case average < 150*time.Millisecond && jitter < 50*time.Millisecond:
+		return QualityGood
+	case average < 400*time.Millisecond && jitter < 150*time.Millisecond:
+		return QualityFair
+	default:
+		return QualityPoor
+	}
+}
+
+// resetLatency discards all measurements. It is called whenever a connection
+// is established.
+func (c *Client) resetLatency() {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	warned := c.latency.warned
+	c.latency = latencyStats{warned: warned}
+}
+
+// keepAlive sends a command to the server periodically until done is closed,
+// so that both the client and the server detect connections which were
+// silently dropped. When the server supports the ping command, the
+// round-trip time is measured by the time taken for the server to echo each
+// ping. Otherwise, the list of matches is requested and the round-trip time is
+// measured by the time taken for the server to respond. A pong, which every
+// server accepts and ignores, is sent while a request is pending.
+func (c *Client) keepAlive(t Transport, done chan struct{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		command := []byte("pong")
+		c.stateLock.Lock()
+		if c.capabilities[CapabilityPing] {
+			// Pings which were never answered are discarded.
+			if c.latency.pingSent.IsZero() || time.Since(c.latency.pingSent) > readTimeout {
+				c.latency.ping++
+				c.latency.pingSent = time.Now()
+				command = []byte("ping " + strconv.Itoa(c.latency.ping))
+			}
+		} else if c.state == StateConnected && c.latency.expireLists() == 0 {
+			c.latency.lists = append(c.latency.lists, listRequest{sent: time.Now(), probe: true})
+			command = []byte("list")
+		}
+		c.stateLock.Unlock()
+
+		err := t.Write(command)
+		if err != nil {
+			t.Close()
+			return
+		}
+		atomic.AddUint64(&c.metrics.commands, 1)
+		c.record(DirectionOut, command)
+	}
+}
+
+// pingReceived measures the round-trip time when a ping event echoes the
+// pending ping sent by the client. It returns whether the event was an echo.
+func (c *Client) pingReceived(message string) bool {
+	c.stateLock.Lock()
+	if c.latency.pingSent.IsZero() || message != strconv.Itoa(c.latency.ping) {
+		c.stateLock.Unlock()
+		return false
+	}
+	sent := c.latency.pingSent
+	c.latency.pingSent = time.Time{}
+	c.stateLock.Unlock()
+
+	c.addLatency(time.Since(sent))
+	return true
+}
+
+// expireLists discards list requests which were never answered and returns
+// the number of requests which are pending.
+func (s *latencyStats) expireLists() int {
+	for len(s.lists) > 0 && time.Since(s.lists[0].sent) > readTimeout {
+		s.lists = s.lists[1:]
+	}
+	return len(s.lists)
+}
+
+// listSent records the time a list request was sent while the server is not
+// known to echo pings. It must be called before the command is
+// written.
+func (c *Client) listSent(command []byte) {
+	if commandName(command) != "ls" {
+		return
+	}
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	if c.capabilities[CapabilityPing] {
+		return
+	}
+	c.latency.expireLists()
+	c.latency.lists = append(c.latency.lists, listRequest{sent: time.Now()})
+}
+
+// listReceived measures the round-trip time of the oldest pending list
+// request. It returns whether the response was requested by keepAlive.
+func (c *Client) listReceived() bool {
+	c.stateLock.Lock()
+	if c.latency.expireLists() == 0 {
+		c.stateLock.Unlock()
+		return false
+	}
+	request := c.latency.lists[0]
+	c.latency.lists = c.latency.lists[1:]
+	c.stateLock.Unlock()
+
+	c.addLatency(time.Since(request.sent))
+	return request.probe
+}
+
+// FormatLatency formats a round-trip time for display.
+func FormatLatency(d time.Duration) string {
+	if d >= time.Second {
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
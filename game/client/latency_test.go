@@ -0,0 +1,43 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyStats(t *testing.T) {
+	s := &latencyStats{}
+	if average, jitter := s.average(); average != 0 || jitter != 0 {
+		t.Fatalf("expected no latency, got %s±%s", average, jitter)
+	}
+
+	s.add(100 * time.Millisecond)
+	s.add(200 * time.Millisecond)
+	s.add(150 * time.Millisecond)
+	if average, jitter := s.average(); average != 150*time.Millisecond || jitter != 75*time.Millisecond {
+		t.Fatalf("expected 150ms±75ms, got %s±%s", average, jitter)
+	}
+
+	// Only the most recent measurements are included.
+	for i := 0; i < latencySamples; i++ {
+		s.add(time.Second)
+	}
+	if average, jitter := s.average(); average != time.Second || jitter != 0 {
+		t.Fatalf("expected 1s±0s, got %s±%s", average, jitter)
+	}
+}
+
+func TestFormatLatency(t *testing.T) {
+	testCases := []struct {
+		latency  time.Duration
+		expected string
+	}{
+		{42*time.Millisecond + 400*time.Microsecond, "42ms"},
+		{1234 * time.Millisecond, "1.2s"},
+	}
+	for _, tc := range testCases {
+		if formatted := FormatLatency(tc.latency); formatted != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.latency, tc.expected, formatted)
+		}
+	}
+}
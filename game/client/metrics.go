@@ -0,0 +1,118 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// clientMetrics are the metrics of a single server connection. Counters are
+// updated atomically. events is guarded by Client.stateLock.
+type clientMetrics struct {
+	reconnects uint64
+	commands   uint64
+	latency    int64 // Last measured round-trip time in nanoseconds.
+	events     map[string]uint64
+}
+
+// eventType returns the name of the type of an event as used in metrics.
+func eventType(ev interface{}) string {
+	t := reflect.TypeOf(ev)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.ToLower(strings.TrimPrefix(t.Name(), "Event"))
+}
+
+func (c *Client) countEvent(ev interface{}) {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	if c.metrics.events == nil {
+		c.metrics.events = make(map[string]uint64)
+	}
+	c.metrics.events[eventType(ev)]++
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// MetricsWriter writes metrics in the Prometheus text format.
+type MetricsWriter struct {
+	bytes.Buffer
+}
+
+// Header writes the description and type of a metric.
+func (w *MetricsWriter) Header(name string, metricType string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// Value writes a value of a metric. Labels are specified as pairs of names
+// and values.
+func (w *MetricsWriter) Value(name string, labels []string, value interface{}) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i := 0; i < len(labels)-1; i += 2 {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, labels[i], labelReplacer.Replace(labels[i+1]))
+		}
+		w.WriteByte('}')
+	}
+	fmt.Fprintf(w, " %v\n", value)
+}
+
+// WriteClients writes the metrics of the connections of the provided clients.
+func (w *MetricsWriter) WriteClients(clients []*Client) {
+	w.Header("boxcars_connection_state", "gauge", "Connection state of each server connection.")
+	for _, c := range clients {
+		current := c.State()
+		for state := StateDisconnected; state <= StateBackoff; state++ {
+			var value int
+			if state == current {
+				value = 1
+			}
+			w.Value("boxcars_connection_state", []string{"address", c.Address, "state", state.String()}, value)
+		}
+	}
+
+	w.Header("boxcars_reconnects_total", "counter", "Number of attempts to reconnect after the connection was lost or failed.")
+	for _, c := range clients {
+		w.Value("boxcars_reconnects_total", []string{"address", c.Address}, atomic.LoadUint64(&c.metrics.reconnects))
+	}
+
+	w.Header("boxcars_events_received_total", "counter", "Number of events received from the server by type.")
+	for _, c := range clients {
+		c.stateLock.Lock()
+		types := make([]string, 0, len(c.metrics.events))
+		counts := make(map[string]uint64, len(c.metrics.events))
+		for t, count := range c.metrics.events {
+			types = append(types, t)
+			counts[t] = count
+		}
+		c.stateLock.Unlock()
+
+		sort.Strings(types)
+		for _, t := range types {
+			w.Value("boxcars_events_received_total", []string{"address", c.Address, "type", t}, counts[t])
+		}
+	}
+
+	w.Header("boxcars_commands_sent_total", "counter", "Number of commands sent to the server.")
+	for _, c := range clients {
+		w.Value("boxcars_commands_sent_total", []string{"address", c.Address}, atomic.LoadUint64(&c.metrics.commands))
+	}
+
+	w.Header("boxcars_latency_seconds", "gauge", "Last measured round-trip time to the server.")
+	for _, c := range clients {
+		latency := time.Duration(atomic.LoadInt64(&c.metrics.latency))
+		if latency == 0 {
+			continue
+		}
+		w.Value("boxcars_latency_seconds", []string{"address", c.Address}, latency.Seconds())
+	}
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+)
+
+func TestEventType(t *testing.T) {
+	testCases := []struct {
+		event    interface{}
+		expected string
+	}{
+		{&bgammon.EventBoard{}, "board"},
+		{&bgammon.EventFailedJoin{}, "failedjoin"},
+		{&EventConnectionState{}, "connectionstate"},
+	}
+	for _, tc := range testCases {
+		if name := eventType(tc.event); name != tc.expected {
+			t.Errorf("%T: expected %q, got %q", tc.event, tc.expected, name)
+		}
+	}
+}
+
+func TestMetricsWriter(t *testing.T) {
+	c := NewClient("tcp://localhost:1337", "player", "")
+	c.countEvent(&bgammon.EventBoard{})
+	c.countEvent(&bgammon.EventBoard{})
+	c.countEvent(&bgammon.EventSay{})
+
+	m := &MetricsWriter{}
+	m.WriteClients([]*Client{c})
+	output := m.String()
+
+	for _, expected := range []string{
+		"# TYPE boxcars_connection_state gauge\n",
+		`boxcars_connection_state{address="tcp://localhost:1337",state="` + StateDisconnected.String() + `"} 1` + "\n",
+		`boxcars_events_received_total{address="tcp://localhost:1337",type="board"} 2` + "\n",
+		`boxcars_events_received_total{address="tcp://localhost:1337",type="say"} 1` + "\n",
+		`boxcars_commands_sent_total{address="tcp://localhost:1337"} 0` + "\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "boxcars_latency_seconds{") {
+		t.Errorf("expected no latency before it was measured, got:\n%s", output)
+	}
+}
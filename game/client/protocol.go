@@ -0,0 +1,128 @@
+package client
+
+import (
+	"strings"
+	"time"
+)
+
+// ProtocolVersion is the version of the protocol implemented by the client.
+// It is announced to the server after logging in.
+const ProtocolVersion = 1
+
+// Server capabilities. Each capability is named after the command which
+// provides it.
+const (
+	CapabilityRematch       = "rematch"
+	CapabilityWatch         = "watch"
+	CapabilityTV            = "tv"
+	CapabilityRegister      = "register"
+	CapabilityResetPassword = "resetpassword"
+	CapabilityPing          = "ping"
+)
+
+// knownCapabilities are the capabilities recognized by the client.
+var knownCapabilities = []string{
+	CapabilityRematch,
+	CapabilityWatch,
+	CapabilityTV,
+	CapabilityRegister,
+	CapabilityResetPassword,
+	CapabilityPing,
+}
+
+// EventCapabilities is sent via Client.Events once the capabilities of the
+// server have been learned from its response to the help command, which is
+// requested after logging in. It is not sent by the server.
+type EventCapabilities struct {
+	Capabilities []string // Nil when the help text lists no known commands.
+}
+
+// RawEvent is a message received from the server which could not be decoded.
+type RawEvent struct {
+	Time time.Time
+	Data []byte
+	Err  error
+}
+
+// maxRawEvents is the number of undecodable messages kept by each client.
+const maxRawEvents = 100
+
+// capabilitiesFromHelp returns the capabilities advertised in the help text
+// sent by the server. The help text lists one command per line, followed by
+// its arguments and description. Only the commands listed are considered
+// supported. Nil is returned when no known command is listed, as the help
+// text is then in a format which is not understood.
+func capabilitiesFromHelp(message string) []string {
+	var capabilities []string
+	for _, line := range strings.Split(message, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		command := strings.ToLower(strings.TrimPrefix(fields[0], "/"))
+		for _, capability := range knownCapabilities {
+			if command == capability {
+				capabilities = append(capabilities, capability)
+				break
+			}
+		}
+	}
+	return capabilities
+}
+
+// HasCapability returns whether the server supports the specified capability.
+// Every capability is assumed to be supported until the capabilities of the
+// server are known, so that features are only disabled when the server does
+// not advertise them.
+func (c *Client) HasCapability(capability string) bool {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	return c.capabilities == nil || c.capabilities[capability]
+}
+
+// advertised returns whether the server is known to support the specified
+// capability.
+func (c *Client) advertised(capability string) bool {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	return c.capabilities[capability]
+}
+
+// RawEvents returns the most recent messages received from the server which
+// could not be decoded.
+func (c *Client) RawEvents() []*RawEvent {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	events := make([]*RawEvent, len(c.rawEvents))
+	copy(events, c.rawEvents)
+	return events
+}
+
+// setCapabilities sets the capabilities of the server. The capabilities are
+// unknown when nil is specified.
+func (c *Client) setCapabilities(capabilities []string) {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	if capabilities == nil {
+		c.capabilities = nil
+		return
+	}
+	c.capabilities = make(map[string]bool)
+	for _, capability := range capabilities {
+		c.capabilities[capability] = true
+	}
+}
+
+// addRawEvent adds a message which could not be decoded to the raw event log.
+func (c *Client) addRawEvent(msg []byte, err error) {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	if len(c.rawEvents) == maxRawEvents {
+		c.rawEvents = append(c.rawEvents[:0], c.rawEvents[1:]...)
+	}
+	c.rawEvents = append(c.rawEvents, &RawEvent{
+		Time: time.Now(),
+		Data: append([]byte(nil), msg...),
+		Err:  err,
+	})
+}
@@ -0,0 +1,33 @@
+//go:build !js || !wasm
+
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+
+	"nhooyr.io/websocket"
+)
+
+// webSocketDialOptions returns the options used to establish WebSocket
+// connections through the specified proxy, or directly when proxy is nil. The
+// proxy has already been resolved, so proxies specified via the environment
+// are never used by the HTTP client.
+func webSocketDialOptions(proxy *url.URL) *websocket.DialOptions {
+	transport := &http.Transport{
+		Proxy:       nil,
+		DialContext: dialer.DialContext,
+	}
+	if proxy != nil {
+		transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+			return dialProxy(proxy, address)
+		}
+	}
+	return &websocket.DialOptions{
+		HTTPClient: &http.Client{
+			Transport: transport,
+		},
+	}
+}
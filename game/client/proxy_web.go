@@ -0,0 +1,15 @@
+//go:build js && wasm
+
+package client
+
+import (
+	"net/url"
+
+	"nhooyr.io/websocket"
+)
+
+// webSocketDialOptions returns nil, as browsers connect through the proxy
+// configured by the user.
+func webSocketDialOptions(proxy *url.URL) *websocket.DialOptions {
+	return nil
+}
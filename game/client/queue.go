@@ -0,0 +1,203 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxQueuedCommands = 100             // Number of unsent commands kept.
+	maxCommandAge     = 2 * time.Minute // Time unsent commands are kept.
+)
+
+var (
+	errQueueFull      = errors.New("too many commands waiting to be sent")
+	errCommandExpired = errors.New("the connection was not restored in time")
+	errCommandStale   = errors.New("the connection was lost before it was sent")
+)
+
+// EventCommandFailed is sent via Client.Events when a command is dropped
+// without being sent to the server. Passwords are redacted from Command.
+type EventCommandFailed struct {
+	Command string
+	Err     error
+}
+
+type queuedCommand struct {
+	data   []byte
+	queued time.Time
+}
+
+// commandQueue holds commands until they are written to the server. Commands
+// are kept while the client is disconnected and are sent once the connection
+// is restored. Adding a command never blocks.
+type commandQueue struct {
+	commands []*queuedCommand
+	ready    chan struct{} // Signaled when a command is added.
+
+	failed      []*EventCommandFailed // Failures not yet sent via Client.Events.
+	failedReady chan struct{}         // Signaled when a failure is added.
+
+	sync.Mutex
+}
+
+func newCommandQueue() *commandQueue {
+	return &commandQueue{
+		ready:       make(chan struct{}, 1),
+		failedReady: make(chan struct{}, 1),
+	}
+}
+
+// push adds a command to the queue. Requests for the list of matches and the
+// game state are not added when the same request is already waiting to be
+// sent. When the queue is full, the command is returned as dropped.
+func (q *commandQueue) push(command []byte) (dropped *queuedCommand) {
+	q.Lock()
+	defer q.Unlock()
+
+	name := commandName(command)
+	if name == "ls" || name == "board" {
+		for _, queued := range q.commands {
+			if commandName(queued.data) == name {
+				return nil
+			}
+		}
+	}
+
+	cmd := &queuedCommand{
+		data:   command,
+		queued: time.Now(),
+	}
+	if len(q.commands) >= maxQueuedCommands {
+		return cmd
+	}
+	q.commands = append(q.commands, cmd)
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// pop removes and returns the next command, or nil when the queue is empty.
+func (q *commandQueue) pop() *queuedCommand {
+	q.Lock()
+	defer q.Unlock()
+
+	if len(q.commands) == 0 {
+		return nil
+	}
+	cmd := q.commands[0]
+	q.commands[0] = nil
+	q.commands = q.commands[1:]
+	return cmd
+}
+
+// unpop returns a command which could not be written to the front of the
+// queue.
+func (q *commandQueue) unpop(cmd *queuedCommand) {
+	q.Lock()
+	defer q.Unlock()
+
+	q.commands = append([]*queuedCommand{cmd}, q.commands...)
+}
+
+// expire removes and returns commands which should no longer be sent after
+// reconnecting: commands which have waited longer than maxCommandAge, and
+// commands which act on the match in progress, as the match may have changed
+// while disconnected.
+func (q *commandQueue) expire() (expired []*queuedCommand, stale []*queuedCommand) {
+	q.Lock()
+	defer q.Unlock()
+
+	now := time.Now()
+	commands := q.commands[:0]
+	for _, cmd := range q.commands {
+		if now.Sub(cmd.queued) > maxCommandAge {
+			expired = append(expired, cmd)
+		} else if isMatchCommand(cmd.data) {
+			stale = append(stale, cmd)
+		} else {
+			commands = append(commands, cmd)
+		}
+	}
+	for i := len(commands); i < len(q.commands); i++ {
+		q.commands[i] = nil
+	}
+	q.commands = commands
+	return expired, stale
+}
+
+// fail adds the failure of a dropped command to the failures waiting to be
+// sent via Client.Events.
+func (q *commandQueue) fail(ev *EventCommandFailed) {
+	q.Lock()
+	defer q.Unlock()
+
+	q.failed = append(q.failed, ev)
+
+	select {
+	case q.failedReady <- struct{}{}:
+	default:
+	}
+}
+
+// takeFailed removes and returns the failures waiting to be sent.
+func (q *commandQueue) takeFailed() []*EventCommandFailed {
+	q.Lock()
+	defer q.Unlock()
+
+	failed := q.failed
+	q.failed = nil
+	return failed
+}
+
+// commandName returns the name of a command, with aliases of commands which
+// are coalesced normalized.
+func commandName(command []byte) string {
+	fields := strings.Fields(string(command))
+	if len(fields) == 0 {
+		return ""
+	}
+	name := strings.ToLower(fields[0])
+	if name == "list" {
+		return "ls"
+	}
+	return name
+}
+
+// isMatchCommand returns whether a command acts on the match in progress.
+func isMatchCommand(command []byte) bool {
+	switch commandName(command) {
+	case "roll", "r", "mv", "m", "move", "ok", "k", "double", "d", "resign", "reset":
+		return true
+	default:
+		return false
+	}
+}
+
+// commandFailed reports a command which was dropped without being sent.
+// Commands may be dropped by the goroutine which handles events, so the
+// failure is sent via Client.Events by the goroutine which connects to the
+// server, in order with the events received from the server.
+func (c *Client) commandFailed(cmd *queuedCommand, err error) {
+	c.queue.fail(&EventCommandFailed{
+		Command: redactCommand(string(cmd.data)),
+		Err:     err,
+	})
+}
+
+// sendEvent sends an event via Client.Events, preceded by the failures of any
+// commands dropped since the last event was sent. When ev is nil, only the
+// failures are sent.
+func (c *Client) sendEvent(ev interface{}) {
+	for _, failed := range c.queue.takeFailed() {
+		c.Events <- failed
+	}
+	if ev != nil {
+		c.Events <- ev
+	}
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// queued returns the commands waiting to be sent.
+func queued(q *commandQueue) []string {
+	q.Lock()
+	defer q.Unlock()
+	var commands []string
+	for _, cmd := range q.commands {
+		commands = append(commands, string(cmd.data))
+	}
+	return commands
+}
+
+func TestCommandQueue(t *testing.T) {
+	q := newCommandQueue()
+	for _, command := range []string{"ls", "board", "say hello", "list", "b", "board", "mv 13/7"} {
+		if dropped := q.push([]byte(command)); dropped != nil {
+			t.Fatalf("unexpected dropped command: %s", dropped.data)
+		}
+	}
+	// Requests for the list of matches and the game state are coalesced.
+	expected := fmt.Sprint([]string{"ls", "board", "say hello", "b", "mv 13/7"})
+	if commands := fmt.Sprint(queued(q)); commands != expected {
+		t.Fatalf("expected %s, got %s", expected, commands)
+	}
+
+	cmd := q.pop()
+	if string(cmd.data) != "ls" {
+		t.Fatalf("expected ls, got %s", cmd.data)
+	}
+	q.unpop(cmd)
+	if cmd := q.pop(); string(cmd.data) != "ls" {
+		t.Fatalf("expected unpopped command to be sent first, got %s", cmd.data)
+	}
+	for q.pop() != nil {
+	}
+
+	for i := 0; i < maxQueuedCommands; i++ {
+		if dropped := q.push([]byte(fmt.Sprintf("say %d", i))); dropped != nil {
+			t.Fatalf("unexpected dropped command: %s", dropped.data)
+		}
+	}
+	if dropped := q.push([]byte("say full")); dropped == nil || string(dropped.data) != "say full" {
+		t.Fatal("expected command to be dropped when the queue is full")
+	}
+}
+
+func TestCommandQueueExpire(t *testing.T) {
+	q := newCommandQueue()
+	for _, command := range []string{"say old", "roll", "ls", "mv 13/7", "say new", "ok"} {
+		q.push([]byte(command))
+	}
+	q.commands[0].queued = time.Now().Add(-maxCommandAge - time.Second)
+
+	expired, stale := q.expire()
+	if len(expired) != 1 || string(expired[0].data) != "say old" {
+		t.Fatalf("expected old command to expire, got %v", expired)
+	}
+	var staleCommands []string
+	for _, cmd := range stale {
+		staleCommands = append(staleCommands, string(cmd.data))
+	}
+	if fmt.Sprint(staleCommands) != fmt.Sprint([]string{"roll", "mv 13/7", "ok"}) {
+		t.Fatalf("expected match commands to be stale, got %v", staleCommands)
+	}
+	if commands := fmt.Sprint(queued(q)); commands != fmt.Sprint([]string{"ls", "say new"}) {
+		t.Fatalf("unexpected commands kept: %s", commands)
+	}
+}
+
+func TestCommandFailedOrder(t *testing.T) {
+	c := NewClient("pipe", "", "")
+	for i := 0; i < maxQueuedCommands; i++ {
+		c.Send(fmt.Sprintf("say %d", i))
+	}
+	c.Send("j 1 secret")
+	c.Send("say dropped")
+	c.sendEvent(&EventConnectionState{State: StateBackoff})
+
+	// Failures are delivered in order, before the next event, with
+	// passwords redacted.
+	for _, expected := range []string{"j 1 ***", "say dropped"} {
+		ev, ok := (<-c.Events).(*EventCommandFailed)
+		if !ok || ev.Command != expected || !errors.Is(ev.Err, errQueueFull) {
+			t.Fatalf("expected failure of %q, got %+v", expected, ev)
+		}
+	}
+	if _, ok := (<-c.Events).(*EventConnectionState); !ok {
+		t.Fatal("expected connection state to follow command failures")
+	}
+}
@@ -0,0 +1,237 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DirectionIn  = "in"  // Message received from the server.
+	DirectionOut = "out" // Command sent to the server.
+)
+
+// RecordedMessage is a single line of a session recording.
+type RecordedMessage struct {
+	Time      time.Time `json:"time"`
+	Session   int       `json:"session,omitempty"` // Session the message was exchanged in.
+	Direction string    `json:"direction"`
+	Data      string    `json:"data"`
+}
+
+// Recorder writes a recording of the messages exchanged with the server by
+// one or more clients, one JSON encoded RecordedMessage per line. Each line
+// is tagged with the session of the client. Passwords are redacted.
+type Recorder struct {
+	w        io.Writer
+	encoder  *json.Encoder
+	sessions int // Number of sessions recorded.
+	sync.Mutex
+}
+
+// NewRecorder returns a recorder which writes to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		w:       w,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// newSession returns the number used to tag the messages of a session.
+func (r *Recorder) newSession() int {
+	r.Lock()
+	defer r.Unlock()
+	r.sessions++
+	return r.sessions
+}
+
+func (r *Recorder) record(session int, direction string, data []byte) {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+
+	if r.encoder == nil {
+		return
+	}
+	msg := string(data)
+	if direction == DirectionOut {
+		msg = redactCommand(msg)
+	}
+	err := r.encoder.Encode(&RecordedMessage{
+		Time:      time.Now(),
+		Session:   session,
+		Direction: direction,
+		Data:      msg,
+	})
+	if err != nil {
+		log.Printf("error: failed to record session: %s", err)
+		r.encoder = nil
+	}
+}
+
+// Close stops recording. The writer passed to NewRecorder is closed when it
+// implements io.Closer.
+func (r *Recorder) Close() error {
+	r.Lock()
+	defer r.Unlock()
+	r.encoder = nil
+	if closer, ok := r.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// redactCommand removes account and match passwords from a command.
+func redactCommand(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return command
+	}
+	keep := 0
+	switch strings.ToLower(fields[0]) {
+	case "lj", "loginjson", "l", "login":
+		keep = 3 // Command, client name and username.
+	case "rj", "registerjson", "register":
+		keep = 4 // Command, client name, email and username.
+	case "j", "join":
+		keep = 2 // Command and match ID.
+	case "c", "create":
+		// Command, match type, password, points and name.
+		if len(fields) > 2 && strings.EqualFold(fields[1], "private") {
+			fields[2] = "***"
+			return strings.Join(fields, " ")
+		}
+		return command
+	default:
+		return command
+	}
+	if len(fields) <= keep {
+		return command
+	}
+	return strings.Join(fields[:keep], " ") + " ***"
+}
+
+// Record records every message received from the server and every command
+// sent to the server using r. The recorder may be shared by several clients.
+func (c *Client) Record(r *Recorder) {
+	session := r.newSession()
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	c.recorder = r
+	c.recordSession = session
+}
+
+// StopRecording stops recording the session. The recorder is not closed, as
+// it may be shared by other clients.
+func (c *Client) StopRecording() {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	c.recorder = nil
+}
+
+// record records a message exchanged with the server when recording.
+func (c *Client) record(direction string, data []byte) {
+	c.stateLock.Lock()
+	r, session := c.recorder, c.recordSession
+	c.stateLock.Unlock()
+	r.record(session, direction, data)
+}
+
+// ReplayTransport is a Transport which replays the messages received from the
+// server in a session recording. When the recording contains several
+// sessions, only the first session is replayed. Commands written to the
+// transport are discarded. After the last message is replayed, Read blocks
+// until the transport is closed.
+type ReplayTransport struct {
+	scanner *bufio.Scanner
+	speed   float64
+	last    time.Time
+	session int // Session replayed, or -1 before the first message is read.
+	closed  chan struct{}
+	once    *sync.Once
+}
+
+// NewReplayTransport returns a transport which replays the recording read
+// from r. Messages are replayed at the specified multiple of real time. When
+// speed is zero, messages are replayed without delay.
+func NewReplayTransport(r io.Reader, speed float64) *ReplayTransport {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	return &ReplayTransport{
+		scanner: scanner,
+		speed:   speed,
+		session: -1,
+		closed:  make(chan struct{}),
+		once:    &sync.Once{},
+	}
+}
+
+func (t *ReplayTransport) Dial(address string) error {
+	select {
+	case <-t.closed:
+		return errConnectionClosed
+	default:
+		return nil
+	}
+}
+
+func (t *ReplayTransport) Read() ([]byte, error) {
+	for t.scanner.Scan() {
+		line := strings.TrimSpace(t.scanner.Text())
+		if line == "" {
+			continue
+		}
+		msg := &RecordedMessage{}
+		err := json.Unmarshal([]byte(line), msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse recording: %s", err)
+		} else if msg.Direction != DirectionIn || (t.session != -1 && msg.Session != t.session) {
+			continue
+		}
+		t.session = msg.Session
+
+		if !t.last.IsZero() && t.speed > 0 {
+			delay := time.Duration(float64(msg.Time.Sub(t.last)) / t.speed)
+			if delay > 0 {
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-t.closed:
+					timer.Stop()
+					return nil, errConnectionClosed
+				}
+			}
+		}
+		t.last = msg.Time
+		return []byte(msg.Data), nil
+	}
+	if err := t.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %s", err)
+	}
+
+	<-t.closed
+	return nil, errConnectionClosed
+}
+
+func (t *ReplayTransport) Write(command []byte) error {
+	select {
+	case <-t.closed:
+		return errConnectionClosed
+	default:
+		return nil
+	}
+}
+
+func (t *ReplayTransport) Close() error {
+	t.once.Do(func() {
+		close(t.closed)
+	})
+	return nil
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+	"code.rocket9labs.com/tslocum/boxcars/game/fakeserver"
+)
+
+func TestRedactCommand(t *testing.T) {
+	testCases := []struct {
+		command  string
+		expected string
+	}{
+		{"lj boxcars player secret", "lj boxcars player ***"},
+		{"LOGIN boxcars player secret words", "LOGIN boxcars player ***"},
+		{"lj boxcars player", "lj boxcars player"},
+		{"lj boxcars", "lj boxcars"},
+		{"rj boxcars player@example.com player secret", "rj boxcars player@example.com player ***"},
+		{"j 1 secret", "j 1 ***"},
+		{"join 1", "join 1"},
+		{"c private secret 5 My match", "c private *** 5 My match"},
+		{"c public 5 My match", "c public 5 My match"},
+		{"say my password is secret", "say my password is secret"},
+		{"", ""},
+	}
+	for _, tc := range testCases {
+		if redacted := redactCommand(tc.command); redacted != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.command, tc.expected, redacted)
+		}
+	}
+}
+
+// collectEvents reads events from the client until a game state is received,
+// and returns the events received from the server.
+func collectEvents(t *testing.T, c *Client) []interface{} {
+	t.Helper()
+	var events []interface{}
+	waitFor(t, c, func(ev interface{}) bool {
+		switch ev.(type) {
+		case *EventConnectionState, *EventCapabilities:
+			return false
+		}
+		events = append(events, ev)
+		_, ok := ev.(*bgammon.EventBoard)
+		return ok
+	})
+	return events
+}
+
+// TestRecordReplay records a session and replays it, which must result in
+// the same events being received.
+func TestRecordReplay(t *testing.T) {
+	recording := &bytes.Buffer{}
+	recorder := NewRecorder(recording)
+
+	c := pipeClient(fakeserver.NewServer(), "player")
+	c.Password = "secret"
+	c.Record(recorder)
+	go c.Connect()
+	waitForState(t, c, StateConnected)
+	c.CreateMatch(MatchOptions{Name: "Recorded match", Points: 3, Password: "hidden"})
+	recorded := collectEvents(t, c)
+	c.Disconnect()
+	waitForState(t, c, StateDisconnected)
+	recorder.Close()
+
+	if strings.Contains(recording.String(), "secret") || strings.Contains(recording.String(), "hidden") {
+		t.Fatalf("expected passwords to be redacted from recording:\n%s", recording)
+	}
+	var directions []string
+	for _, line := range strings.Split(strings.TrimSpace(recording.String()), "\n") {
+		msg := &RecordedMessage{}
+		err := json.Unmarshal([]byte(line), msg)
+		if err != nil {
+			t.Fatal(err)
+		} else if msg.Session != 1 {
+			t.Fatalf("expected messages to be tagged with session 1, got %d", msg.Session)
+		}
+		directions = append(directions, msg.Direction)
+	}
+	if len(directions) == 0 || directions[0] != DirectionOut {
+		t.Fatalf("expected the login command to be recorded first, got %v", directions)
+	}
+
+	replay := NewReplayTransport(bytes.NewReader(recording.Bytes()), 0)
+	r := NewClient("replay", "", "")
+	r.NewTransport = func() Transport {
+		return replay
+	}
+	go r.Connect()
+	defer r.Disconnect()
+	waitForState(t, r, StateConnected)
+	replayed := collectEvents(t, r)
+
+	if !reflect.DeepEqual(recorded, replayed) {
+		t.Fatalf("replayed events differ from recorded events:\nrecorded: %+v\nreplayed: %+v", recorded, replayed)
+	}
+}
+
+func TestReplayFirstSession(t *testing.T) {
+	start := time.Now()
+	messages := []*RecordedMessage{
+		{Time: start, Session: 2, Direction: DirectionOut, Data: "lj boxcars second"},
+		{Time: start, Session: 1, Direction: DirectionIn, Data: "first 1"},
+		{Time: start, Session: 2, Direction: DirectionIn, Data: "second 1"},
+		{Time: start.Add(50 * time.Millisecond), Session: 1, Direction: DirectionIn, Data: "first 2"},
+	}
+	recording := &bytes.Buffer{}
+	encoder := json.NewEncoder(recording)
+	for _, msg := range messages {
+		encoder.Encode(msg)
+	}
+	recording.WriteString("\n")
+
+	replay := NewReplayTransport(recording, 1)
+	if err := replay.Dial("replay"); err != nil {
+		t.Fatal(err)
+	}
+	if err := replay.Write([]byte("ignored")); err != nil {
+		t.Fatal(err)
+	}
+	received := time.Now()
+	for _, expected := range []string{"first 1", "first 2"} {
+		msg, err := replay.Read()
+		if err != nil {
+			t.Fatal(err)
+		} else if string(msg) != expected {
+			t.Fatalf("expected %q, got %q", expected, msg)
+		}
+	}
+	if elapsed := time.Since(received); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected messages to be replayed in real time, replayed in %s", elapsed)
+	}
+
+	// Reading past the end blocks until the transport is closed.
+	read := make(chan error, 1)
+	go func() {
+		_, err := replay.Read()
+		read <- err
+	}()
+	select {
+	case err := <-read:
+		t.Fatalf("expected read to block, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	replay.Close()
+	if err := <-read; !errors.Is(err, errConnectionClosed) {
+		t.Fatalf("expected %v, got %v", errConnectionClosed, err)
+	}
+}
+
+func TestReplayInvalidRecording(t *testing.T) {
+	replay := NewReplayTransport(strings.NewReader("not json\n"), 0)
+	if _, err := replay.Read(); err == nil {
+		t.Fatal("expected error reading invalid recording")
+	}
+}
@@ -0,0 +1,408 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+// Transport is a connection to a bgammon server. Each message read or
+// written is a single line of the protocol without a trailing newline.
+// Write may be called concurrently, and Close may be called concurrently
+// with Read and Write.
+type Transport interface {
+	// Dial connects to the server at the specified address.
+	Dial(address string) error
+
+	// Read returns the next event received from the server. The returned
+	// slice is owned by the caller and is not modified by later reads.
+	Read() ([]byte, error)
+
+	// Write sends a command to the server.
+	Write(command []byte) error
+
+	// Close closes the connection.
+	Close() error
+}
+
+// newTransport returns a Transport suitable for the specified address. When
+// proxy is non-nil, the connection is established through the proxy.
+func newTransport(address string, tlsOptions *TLSOptions, proxy *url.URL) Transport {
+	if strings.HasPrefix(address, "ws://") || strings.HasPrefix(address, "wss://") {
+		return &webSocketTransport{proxy: proxy}
+	} else if strings.HasPrefix(address, "tls://") {
+		if tlsOptions == nil {
+			tlsOptions = &TLSOptions{}
+		}
+		return &tcpTransport{tls: tlsOptions, proxy: proxy}
+	}
+	return &tcpTransport{proxy: proxy}
+}
+
+// TLSOptions configures connections to servers using the tls:// scheme.
+type TLSOptions struct {
+	// CAFile is the path to a PEM encoded bundle of certificate authorities
+	// to trust instead of the system certificate pool.
+	CAFile string
+
+	// Pins is a list of SHA-256 hashes of public keys, encoded as base64 or
+	// hex. When set, the certificate chain presented by the server must
+	// contain at least one of the public keys.
+	Pins []string
+}
+
+func (o *TLSOptions) config(address string) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		ServerName: host,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if o.CAFile != "" {
+		buf, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate authorities: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buf) {
+			return nil, fmt.Errorf("failed to read certificate authorities: no certificates found in %s", o.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if len(o.Pins) > 0 {
+		pins := make(map[string]bool)
+		for _, pin := range o.Pins {
+			pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+			b, err := base64.StdEncoding.DecodeString(pin)
+			if err != nil || len(b) != sha256.Size {
+				b, err = hex.DecodeString(strings.ReplaceAll(pin, ":", ""))
+				if err != nil || len(b) != sha256.Size {
+					return nil, fmt.Errorf("invalid certificate pin: %s", pin)
+				}
+			}
+			pins[string(b)] = true
+		}
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			for _, cert := range state.PeerCertificates {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				if pins[string(sum[:])] {
+					return nil
+				}
+			}
+			return errCertificatePin
+		}
+	}
+	return config, nil
+}
+
+var errCertificatePin = errors.New("certificate does not match any pinned public key")
+
+// IsCertificateError returns whether an error was caused by a failure to
+// verify the certificate presented by the server.
+func IsCertificateError(err error) bool {
+	var (
+		unknownAuthorityError x509.UnknownAuthorityError
+		certificateError      x509.CertificateInvalidError
+		hostnameError         x509.HostnameError
+	)
+	return errors.Is(err, errCertificatePin) || errors.As(err, &unknownAuthorityError) || errors.As(err, &certificateError) || errors.As(err, &hostnameError)
+}
+
+const (
+	dialTimeout  = 10 * time.Second
+	readTimeout  = 40 * time.Second
+	writeTimeout = 10 * time.Second
+	pingInterval = 15 * time.Second
+)
+
+// dialer establishes TCP connections to servers and proxies. Keepalive probes
+// are sent so that connections silently dropped by the network are detected.
+var dialer = &net.Dialer{
+	Timeout:   dialTimeout,
+	KeepAlive: pingInterval,
+}
+
+// errReadTimeout is returned by transports when nothing, not even a ping, is
+// received from the server for readTimeout. The connection is considered
+// dead and is closed.
+var errReadTimeout = fmt.Errorf("no response from server in %s", readTimeout)
+
+// isClosed returns whether an error was caused by the server closing the
+// connection, rather than by the connection failing.
+func isClosed(err error) bool {
+	return errors.Is(err, errConnectionClosed) || errors.Is(err, io.EOF) || websocket.CloseStatus(err) != -1
+}
+
+// isTimeout returns whether an error was caused by a timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+type tcpTransport struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+
+	tls   *TLSOptions // Connect using TLS when set.
+	proxy *url.URL    // Connect through proxy when set.
+}
+
+func (t *tcpTransport) Dial(address string) error {
+	address = strings.TrimPrefix(strings.TrimPrefix(address, "tcp://"), "tls://")
+
+	var conn net.Conn
+	var err error
+	if t.proxy != nil {
+		conn, err = dialProxy(t.proxy, address)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+
+	if t.tls != nil {
+		config, err := t.tls.config(address)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		tlsConn := tls.Client(conn, config)
+		tlsConn.SetDeadline(time.Now().Add(dialTimeout))
+		err = tlsConn.Handshake()
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		tlsConn.SetDeadline(time.Time{})
+		conn = tlsConn
+	}
+
+	// Read a single line of text and parse remaining output as JSON.
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	buf := make([]byte, 1)
+	var readBytes int
+	for {
+		_, err = conn.Read(buf)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+
+		if buf[0] == '\n' {
+			break
+		}
+
+		readBytes++
+		if readBytes == 512 {
+			conn.Close()
+			return fmt.Errorf("failed to read server greeting")
+		}
+	}
+
+	t.conn = conn
+	t.scanner = bufio.NewScanner(conn)
+	return nil
+}
+
+func (t *tcpTransport) Read() ([]byte, error) {
+	t.conn.SetReadDeadline(time.Now().Add(readTimeout))
+
+	if !t.scanner.Scan() {
+		err := t.scanner.Err()
+		if err == nil {
+			err = errConnectionClosed
+		} else if isTimeout(err) {
+			err = errReadTimeout
+		}
+		return nil, err
+	}
+
+	// The buffer of the scanner is overwritten by the next call to Scan.
+	msg := make([]byte, len(t.scanner.Bytes()))
+	copy(msg, t.scanner.Bytes())
+	return msg, nil
+}
+
+func (t *tcpTransport) Write(command []byte) error {
+	t.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+	_, err := t.conn.Write(append(command[:len(command):len(command)], '\n'))
+	return err
+}
+
+func (t *tcpTransport) Close() error {
+	return t.conn.Close()
+}
+
+type webSocketTransport struct {
+	conn  *websocket.Conn
+	proxy *url.URL // Connect through proxy when set.
+
+	done      chan struct{}
+	closeOnce *sync.Once
+}
+
+func (t *webSocketTransport) Dial(address string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, address, webSocketDialOptions(t.proxy))
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	t.done = make(chan struct{})
+	t.closeOnce = &sync.Once{}
+	go t.keepAlive(conn, t.done)
+	return nil
+}
+
+// keepAlive pings the server periodically until the transport is closed. The
+// connection is closed when the server does not respond to a ping in time.
+// The round-trip time is measured by Client.keepAlive instead, as websocket
+// pings are not available when running in a web browser.
+func (t *webSocketTransport) keepAlive(conn *websocket.Conn, done chan struct{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), readTimeout)
+		err := conn.Ping(ctx)
+		cancel()
+		if err != nil {
+			select {
+			case <-done:
+			default:
+				if Debug > 0 {
+					log.Printf("warning: failed to ping server: %s", err)
+				}
+				conn.Close(websocket.StatusGoingAway, "ping timeout")
+			}
+			return
+		}
+	}
+}
+
+func (t *webSocketTransport) Read() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), readTimeout)
+	defer cancel()
+
+	msgType, msg, err := t.conn.Read(ctx)
+	if err != nil {
+		if isTimeout(err) {
+			return nil, errReadTimeout
+		}
+		return nil, err
+	} else if msgType != websocket.MessageText {
+		return nil, fmt.Errorf("unexpected message type: %s", msgType)
+	}
+	return msg, nil
+}
+
+func (t *webSocketTransport) Write(command []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	return t.conn.Write(ctx, websocket.MessageText, command)
+}
+
+func (t *webSocketTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.done)
+	})
+	return t.conn.Close(websocket.StatusNormalClosure, "")
+}
+
+// PipeTransport is an in-memory Transport. Messages written to one end of
+// the pipe are read from the other end. Closing either end closes the pipe.
+type PipeTransport struct {
+	in  chan []byte
+	out chan []byte
+
+	closed    chan struct{}
+	closeOnce *sync.Once
+}
+
+// NewPipeTransport returns both ends of an in-memory pipe. The first end is
+// used as the Transport of a Client, while the second end is used to send
+// events to the client and to read the commands it sends.
+func NewPipeTransport() (*PipeTransport, *PipeTransport) {
+	const bufferSize = 10
+	a, b := make(chan []byte, bufferSize), make(chan []byte, bufferSize)
+	closed := make(chan struct{})
+	closeOnce := &sync.Once{}
+	return &PipeTransport{in: a, out: b, closed: closed, closeOnce: closeOnce},
+		&PipeTransport{in: b, out: a, closed: closed, closeOnce: closeOnce}
+}
+
+// Dial returns an error when the pipe has been closed. The address is ignored.
+func (t *PipeTransport) Dial(address string) error {
+	select {
+	case <-t.closed:
+		return errConnectionClosed
+	default:
+		return nil
+	}
+}
+
+func (t *PipeTransport) Read() ([]byte, error) {
+	select {
+	case msg := <-t.in:
+		return msg, nil
+	case <-t.closed:
+		return nil, errConnectionClosed
+	}
+}
+
+func (t *PipeTransport) Write(msg []byte) error {
+	// Check whether the pipe is closed first, as writes to a closed pipe
+	// would otherwise succeed at random while the buffer has room.
+	select {
+	case <-t.closed:
+		return errConnectionClosed
+	default:
+	}
+
+	buf := make([]byte, len(msg))
+	copy(buf, msg)
+
+	select {
+	case t.out <- buf:
+		return nil
+	case <-t.closed:
+		return errConnectionClosed
+	}
+}
+
+func (t *PipeTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+	})
+	return nil
+}
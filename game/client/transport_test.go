@@ -0,0 +1,286 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"code.rocket9labs.com/tslocum/boxcars/game/fakeserver"
+	"nhooyr.io/websocket"
+)
+
+func TestPipeTransport(t *testing.T) {
+	a, b := NewPipeTransport()
+	if err := a.Dial("pipe"); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := []byte("list")
+	if err := a.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	// Messages are copied when written.
+	msg[0] = 'x'
+	if received, err := b.Read(); err != nil || string(received) != "list" {
+		t.Fatalf("expected list, got %q (%v)", received, err)
+	}
+	if err := b.Write([]byte("event")); err != nil {
+		t.Fatal(err)
+	}
+	if received, err := a.Read(); err != nil || string(received) != "event" {
+		t.Fatalf("expected event, got %q (%v)", received, err)
+	}
+
+	// Closing either end closes the pipe and unblocks readers.
+	read := make(chan error, 1)
+	go func() {
+		_, err := a.Read()
+		read <- err
+	}()
+	b.Close()
+	select {
+	case err := <-read:
+		if !errors.Is(err, errConnectionClosed) {
+			t.Fatalf("expected %v, got %v", errConnectionClosed, err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("read was not unblocked by closing the pipe")
+	}
+	if err := a.Write([]byte("list")); !errors.Is(err, errConnectionClosed) {
+		t.Fatalf("expected %v, got %v", errConnectionClosed, err)
+	} else if err := a.Dial("pipe"); !errors.Is(err, errConnectionClosed) {
+		t.Fatalf("expected %v, got %v", errConnectionClosed, err)
+	} else if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	if _, ok := newTransport("ws://localhost:1337", nil, nil).(*webSocketTransport); !ok {
+		t.Error("expected websocket transport for ws://")
+	}
+	if _, ok := newTransport("wss://localhost:1337", nil, nil).(*webSocketTransport); !ok {
+		t.Error("expected websocket transport for wss://")
+	}
+	if tr, ok := newTransport("tcp://localhost:1337", nil, nil).(*tcpTransport); !ok || tr.tls != nil {
+		t.Error("expected plain TCP transport for tcp://")
+	}
+	if tr, ok := newTransport("tls://localhost:1337", nil, nil).(*tcpTransport); !ok || tr.tls == nil {
+		t.Error("expected TLS transport for tls://")
+	}
+}
+
+// serveTCP serves the fake server via TCP and returns the address of the
+// listener.
+func serveTCP(t *testing.T, wrap func(net.Listener) net.Listener) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	address := listener.Addr().String()
+	if wrap != nil {
+		listener = wrap(listener)
+	}
+	go fakeserver.NewServer().Serve(listener)
+	return address
+}
+
+// connectTo connects a client to the specified address and returns the
+// first connection state which indicates whether the connection succeeded.
+func connectTo(t *testing.T, address string, tlsOptions *TLSOptions, proxy *url.URL) *EventConnectionState {
+	t.Helper()
+	if proxy == nil {
+		proxy = &url.URL{Scheme: "direct"}
+	}
+	c := NewClient(address, "player", "")
+	c.TLS = tlsOptions
+	c.Proxy = proxy
+	go c.Connect()
+	t.Cleanup(c.Disconnect)
+
+	return waitFor(t, c, func(ev interface{}) bool {
+		s, ok := ev.(*EventConnectionState)
+		return ok && (s.State == StateConnected || s.State == StateBackoff || s.State == StateDisconnected)
+	}).(*EventConnectionState)
+}
+
+func TestTCPTransport(t *testing.T) {
+	address := serveTCP(t, nil)
+	if ev := connectTo(t, "tcp://"+address, nil, nil); ev.State != StateConnected {
+		t.Fatalf("failed to connect: %v", ev.Err)
+	}
+}
+
+func TestTCPTransportRead(t *testing.T) {
+	conn, server := net.Pipe()
+	defer server.Close()
+	tr := &tcpTransport{conn: conn, scanner: bufio.NewScanner(conn)}
+	defer tr.Close()
+
+	// The first message is long enough for the scanner to reuse its buffer
+	// when reading the second message.
+	long := strings.Repeat("x", 3000)
+	go server.Write([]byte(long + "\n"))
+	first, err := tr.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Write([]byte("second\n"))
+	second, err := tr.Read()
+	if err != nil {
+		t.Fatal(err)
+	} else if string(first) != long || string(second) != "second" {
+		t.Fatalf("expected messages to remain valid after reading, got %.10q and %q", first, second)
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	conn, other := net.Pipe()
+	defer conn.Close()
+	defer other.Close()
+	conn.SetReadDeadline(time.Now())
+	_, deadlineErr := conn.Read(make([]byte, 1))
+
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{deadlineErr, true},
+		{context.DeadlineExceeded, true},
+		{errConnectionClosed, false},
+		{net.ErrClosed, false},
+	}
+	for _, tc := range testCases {
+		if timeout := isTimeout(tc.err); timeout != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.err, tc.expected, timeout)
+		}
+	}
+}
+
+// testCertificate returns a self-signed certificate for 127.0.0.1 and the
+// base64 encoded SHA-256 hash of its public key.
+func testCertificate(t *testing.T) (tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "boxcars test"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pin := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, base64.StdEncoding.EncodeToString(pin[:])
+}
+
+func TestTLSTransport(t *testing.T) {
+	cert, pin := testCertificate(t)
+	address := "tls://" + serveTCP(t, func(listener net.Listener) net.Listener {
+		return tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{cert}})
+	})
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongPin := strings.Repeat("00", sha256.Size)
+
+	testCases := []struct {
+		name      string
+		options   *TLSOptions
+		connected bool
+	}{
+		{"untrusted", nil, false},
+		{"trusted", &TLSOptions{CAFile: caFile}, true},
+		{"pinned", &TLSOptions{CAFile: caFile, Pins: []string{wrongPin, "sha256/" + pin}}, true},
+		{"wrong pin", &TLSOptions{CAFile: caFile, Pins: []string{wrongPin}}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ev := connectTo(t, address, tc.options, nil)
+			if tc.connected && ev.State != StateConnected {
+				t.Fatalf("failed to connect: %v", ev.Err)
+			} else if !tc.connected && (ev.State != StateBackoff || !IsCertificateError(ev.Err)) {
+				t.Fatalf("expected certificate error, got state %v with %v", ev.State, ev.Err)
+			}
+		})
+	}
+
+	_, err = (&TLSOptions{Pins: []string{"invalid"}}).config("127.0.0.1:1337")
+	if err == nil {
+		t.Fatal("expected invalid pin to be rejected")
+	}
+}
+
+// webSocketConn is a fakeserver.MessageConn which exchanges websocket
+// messages.
+type webSocketConn struct {
+	conn *websocket.Conn
+}
+
+func (c *webSocketConn) Read() ([]byte, error) {
+	_, msg, err := c.conn.Read(context.Background())
+	return msg, err
+}
+
+func (c *webSocketConn) Write(message []byte) error {
+	return c.conn.Write(context.Background(), websocket.MessageText, message)
+}
+
+func (c *webSocketConn) Close() error {
+	return c.conn.Close(websocket.StatusNormalClosure, "")
+}
+
+func TestWebSocketTransport(t *testing.T) {
+	server := fakeserver.NewServer()
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		server.ServeConn(&webSocketConn{conn})
+	}))
+	t.Cleanup(httpServer.Close)
+
+	address := "ws://" + strings.TrimPrefix(httpServer.URL, "http://")
+	if ev := connectTo(t, address, nil, nil); ev.State != StateConnected {
+		t.Fatalf("failed to connect: %v", ev.Err)
+	}
+}
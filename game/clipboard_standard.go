@@ -0,0 +1,41 @@
+//go:build !js || !wasm
+
+package game
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var errClipboardUnavailable = errors.New("clipboard unavailable")
+
+// The clipboard is accessed using the pbcopy command on macOS, the clip
+// command on Windows and the wl-copy, xclip or xsel commands on Linux.
+
+func setClipboard(text string) error {
+	var commands [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		commands = [][]string{{"pbcopy"}}
+	case "windows":
+		commands = [][]string{{"clip"}}
+	case "linux", "freebsd", "openbsd", "netbsd":
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			commands = append(commands, []string{"wl-copy"})
+		}
+		commands = append(commands, []string{"xclip", "-selection", "clipboard"}, []string{"xsel", "--clipboard", "--input"})
+	}
+	for _, command := range commands {
+		path, err := exec.LookPath(command[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return errClipboardUnavailable
+}
@@ -0,0 +1,19 @@
+//go:build js && wasm
+
+package game
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+var errClipboardUnavailable = errors.New("clipboard unavailable")
+
+func setClipboard(text string) error {
+	clipboard := js.Global().Get("navigator").Get("clipboard")
+	if clipboard.IsUndefined() || clipboard.IsNull() {
+		return errClipboardUnavailable
+	}
+	clipboard.Call("writeText", text)
+	return nil
+}
@@ -0,0 +1,61 @@
+package game
+
+import (
+	"bytes"
+	"log"
+
+	"code.rocket9labs.com/tslocum/boxcars/game/match"
+	"github.com/leonelquinteros/gotext"
+)
+
+// exportMatch saves the match shown on the board in the specified format.
+func (b *board) exportMatch(format string) {
+	b.Lock()
+	h := b.history
+	buf := &bytes.Buffer{}
+	var err error
+	if h != nil {
+		err = h.Write(buf, format)
+	}
+	b.Unlock()
+
+	if h == nil {
+		l("*** " + gotext.Get("No moves have been played."))
+		return
+	} else if err != nil {
+		l("*** " + gotext.Get("Failed to export match: %s", err))
+		return
+	}
+
+	p, err := saveMatch(h.FileName(format), buf.Bytes())
+	if err != nil {
+		l("*** " + gotext.Get("Failed to export match: %s", err))
+		return
+	}
+	l("*** " + gotext.Get("Match saved to %s", p))
+}
+
+// autoSaveMatch saves a match which has finished when automatic saving is
+// enabled. Matches are never saved automatically in the browser, where each
+// save starts a download. The board must be locked.
+func autoSaveMatch(h *match.History) {
+	if !ShowAutoSaveMatches || game == nil || game.config == nil || !game.config.AutoSaveMatches {
+		return
+	}
+	buf := &bytes.Buffer{}
+	err := h.WriteMat(buf)
+	if err != nil {
+		log.Printf("error: failed to save match: %s", err)
+		return
+	}
+	name := h.FileName(match.FormatMat)
+	go func() {
+		p, err := saveMatch(name, buf.Bytes())
+		if err != nil {
+			log.Printf("error: failed to save match: %s", err)
+			l("*** " + gotext.Get("Failed to export match: %s", err))
+			return
+		}
+		l("*** " + gotext.Get("Match saved to %s", p))
+	}()
+}
@@ -0,0 +1,36 @@
+//go:build !js || !wasm
+
+package game
+
+import (
+	"os"
+	"path/filepath"
+
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
+)
+
+// matchDir returns the directory matches are saved to.
+func matchDir() (string, error) {
+	if game != nil && game.MatchDir != "" {
+		return game.MatchDir, nil
+	}
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, client.APPNAME), nil
+}
+
+// saveMatch writes a match file to the match directory and returns its path.
+func saveMatch(name string, buf []byte) (string, error) {
+	dir, err := matchDir()
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	p := filepath.Join(dir, name)
+	return p, os.WriteFile(p, buf, 0644)
+}
@@ -0,0 +1,23 @@
+//go:build js && wasm
+
+package game
+
+import (
+	"syscall/js"
+)
+
+// saveMatch offers a match file for download and returns its name.
+func saveMatch(name string, buf []byte) (string, error) {
+	data := js.Global().Get("Uint8Array").New(len(buf))
+	js.CopyBytesToJS(data, buf)
+	blob := js.Global().Get("Blob").New([]interface{}{data}, map[string]interface{}{"type": "text/plain"})
+
+	url := js.Global().Get("URL").Call("createObjectURL", blob)
+	defer js.Global().Get("URL").Call("revokeObjectURL", url)
+
+	link := js.Global().Get("document").Call("createElement", "a")
+	link.Set("href", url)
+	link.Set("download", name)
+	link.Call("click")
+	return name, nil
+}
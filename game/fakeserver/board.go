@@ -0,0 +1,91 @@
+package fakeserver
+
+import "code.rocket9labs.com/tslocum/bgammon"
+
+// Player 1 checkers are stored as positive values and move from space 24
+// towards space 1. Player 2 checkers are stored as negative values and move
+// from space 1 towards space 24. The rules of backgammon are implemented by
+// the bgammon package.
+//
+// As with bgammon.org, each player sees the board from their own side. Player
+// 2 is sent the board with the spaces flipped, so that they also move from
+// space 24 towards space 1, and the moves they send are flipped back.
+
+const checkersPerPlayer = 15
+
+func opponent(player int) int {
+	if player == 1 {
+		return 2
+	}
+	return 1
+}
+
+func homeSpace(player int) int {
+	if player == 1 {
+		return bgammon.SpaceHomePlayer
+	}
+	return bgammon.SpaceHomeOpponent
+}
+
+func barSpace(player int) int {
+	if player == 1 {
+		return bgammon.SpaceBarPlayer
+	}
+	return bgammon.SpaceBarOpponent
+}
+
+// checkers returns the number of checkers the player has on a space.
+func checkers(board []int, space int, player int) int {
+	v := board[space]
+	if player == 2 {
+		v = -v
+	}
+	if v < 0 {
+		return 0
+	}
+	return v
+}
+
+// flipSpace returns the space as seen by the player. Flipping a space twice
+// returns the original space.
+func flipSpace(space int, player int) int {
+	if player == 1 {
+		return space
+	}
+	switch space {
+	case bgammon.SpaceHomePlayer:
+		return bgammon.SpaceHomeOpponent
+	case bgammon.SpaceHomeOpponent:
+		return bgammon.SpaceHomePlayer
+	case bgammon.SpaceBarPlayer:
+		return bgammon.SpaceBarOpponent
+	case bgammon.SpaceBarOpponent:
+		return bgammon.SpaceBarPlayer
+	}
+	return 25 - space
+}
+
+// flipMoves returns the moves as seen by the player.
+func flipMoves(moves [][]int, player int) [][]int {
+	if player == 1 || moves == nil {
+		return moves
+	}
+	flipped := make([][]int, len(moves))
+	for i, move := range moves {
+		flipped[i] = []int{flipSpace(move[0], player), flipSpace(move[1], player)}
+	}
+	return flipped
+}
+
+// localGame returns the game as seen by the player.
+func localGame(g *bgammon.Game, player int) *bgammon.Game {
+	if player == 1 {
+		return g
+	}
+	local := g.Copy()
+	for space := range local.Board {
+		local.Board[space] = g.Board[flipSpace(space, player)]
+	}
+	local.Moves = flipMoves(g.Moves, player)
+	return local
+}
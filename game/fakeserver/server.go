@@ -0,0 +1,883 @@
+// Package fakeserver provides a scriptable stand-in for a bgammon server.
+//
+// The server speaks the same line-based protocol as bgammon.org, which allows
+// clients to be tested and developed without a network connection. Moves are
+// validated using the rules implemented by the bgammon package.
+package fakeserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+)
+
+// MessageConn is a connection to a client. Each message read or written is a
+// single line of the protocol without a trailing newline.
+type MessageConn interface {
+	Read() ([]byte, error)
+	Write(message []byte) error
+	Close() error
+}
+
+// Server is a fake bgammon server.
+type Server struct {
+	// Dice returns the value of the next die rolled. Dice are rolled randomly
+	// when Dice is nil.
+	Dice func() int
+
+	// Board returns the board at the start of each game, stored as described
+	// in board.go. Games start from the standard starting position when Board
+	// is nil.
+	Board func() []int
+
+	// Handler is called before each command is processed. Handler may return
+	// true to skip the default handling of the command.
+	Handler func(c *Conn, command string, args []string) (handled bool)
+
+	conns    []*Conn
+	matches  []*match
+	accounts map[string]bool // Registered usernames, in lowercase.
+
+	nextMatchID int
+	nextGuestID int
+
+	*sync.Mutex
+}
+
+// NewServer returns a new fake server.
+func NewServer() *Server {
+	return &Server{
+		nextMatchID: 1,
+		nextGuestID: 1,
+		accounts:    make(map[string]bool),
+		Mutex:       &sync.Mutex{},
+	}
+}
+
+// FixedDice returns a function suitable for Server.Dice which returns the
+// provided values in order, repeating them once all values have been used.
+func FixedDice(values ...int) func() int {
+	var i int
+	return func() int {
+		v := values[i%len(values)]
+		i++
+		return v
+	}
+}
+
+// ListenAndServe listens for TCP connections on the specified address and
+// serves clients until an error occurs.
+func (s *Server) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve serves clients connecting to the provided listener until an error
+// occurs.
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.Write([]byte("bgammon fake server\n"))
+		if err != nil {
+			conn.Close()
+			continue
+		}
+
+		go s.ServeConn(newLineConn(conn))
+	}
+}
+
+// ServeConn serves a single client and returns when the connection is closed.
+func (s *Server) ServeConn(conn MessageConn) {
+	c := &Conn{
+		conn:   conn,
+		server: s,
+	}
+
+	s.Lock()
+	s.conns = append(s.conns, c)
+	s.Unlock()
+
+	defer func() {
+		s.Lock()
+		s.leaveMatch(c)
+		for i, conn := range s.conns {
+			if conn == c {
+				s.conns = append(s.conns[:i], s.conns[i+1:]...)
+				break
+			}
+		}
+		s.Unlock()
+
+		conn.Close()
+	}()
+
+	for {
+		msg, err := conn.Read()
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(string(msg))
+		if len(fields) == 0 {
+			continue
+		}
+		command, args := strings.ToLower(fields[0]), fields[1:]
+
+		s.Lock()
+		if s.Handler == nil || !s.Handler(c, command, args) {
+			s.handleCommand(c, command, args)
+		}
+		s.Unlock()
+	}
+}
+
+func (s *Server) rollDie() int {
+	if s.Dice != nil {
+		return s.Dice()
+	}
+	return 1 + rand.Intn(6)
+}
+
+func (s *Server) newBoard() []int {
+	if s.Board != nil {
+		return s.Board()
+	}
+	return bgammon.NewBoard()
+}
+
+func (s *Server) handleCommand(c *Conn, command string, args []string) {
+	if c.Name == "" {
+		switch command {
+		case "lj", "l", "login", "loginjson":
+			s.logIn(c, args)
+		case "rj", "register", "registerjson":
+			s.register(c, args)
+		default:
+			c.notice("You must log in before sending other commands.")
+		}
+		return
+	}
+
+	switch command {
+	case "ls", "list":
+		s.sendList(c)
+	case "c", "create":
+		s.createMatch(c, args)
+	case "j", "join":
+		s.joinMatch(c, args)
+	case "leave":
+		if c.match == nil {
+			c.Send(&bgammon.EventFailedLeave{Reason: "You are not in a match."})
+			return
+		}
+		s.leaveMatch(c)
+	case "say", "s":
+		if c.match == nil {
+			c.notice("Message not sent: You are not in a match.")
+			return
+		}
+		opp := c.match.opponent(c)
+		if opp != nil {
+			ev := &bgammon.EventSay{Message: strings.Join(args, " ")}
+			ev.Player = c.Name
+			opp.Send(ev)
+		}
+	case "board", "b":
+		if c.match == nil {
+			c.notice("You are not in a match.")
+			return
+		}
+		c.match.sendBoard(c)
+	case "roll", "r":
+		s.roll(c)
+	case "mv", "m", "move":
+		s.move(c, args)
+	case "ok", "k":
+		s.ok(c)
+	case "double", "d":
+		s.double(c)
+	case "resign":
+		s.resign(c)
+	case "help", "h":
+		c.Send(&bgammon.EventHelp{Message: helpText})
+	case "ping":
+		// Echo the message so that the client may measure the round-trip
+		// time.
+		c.Send(&bgammon.EventPing{Message: strings.Join(args, " ")})
+	case "pong":
+	case "version":
+		// The protocol version announced by the client is accepted, as
+		// there is only one version.
+	default:
+		c.notice(fmt.Sprintf("Unknown command: %s", command))
+	}
+}
+
+func (s *Server) logIn(c *Conn, args []string) {
+	// The first argument is the name of the client.
+	if len(args) > 0 {
+		args = args[1:]
+	}
+
+	name := fmt.Sprintf("Guest%d", s.nextGuestID)
+	if len(args) > 0 && args[0] != "" {
+		name = args[0]
+	} else {
+		s.nextGuestID++
+	}
+	c.Name = name
+
+	ev := &bgammon.EventWelcome{
+		PlayerName: c.Name,
+		Clients:    len(s.conns),
+		Games:      len(s.matches),
+	}
+	c.Send(ev)
+}
+
+// register registers an account and logs in. As with bgammon.org, the
+// connection is closed when registration fails.
+func (s *Server) register(c *Conn, args []string) {
+	// The first argument is the name of the client.
+	if len(args) > 0 {
+		args = args[1:]
+	}
+
+	var reason string
+	switch {
+	case len(args) < 3:
+		reason = "Please enter an email address, username and password."
+	case !strings.Contains(args[0], "@"):
+		reason = "Please enter a valid email address."
+	case s.accounts[strings.ToLower(args[1])]:
+		reason = "That username is already taken."
+	}
+	if reason != "" {
+		c.notice("Failed to register: " + reason)
+		c.conn.Close()
+		return
+	}
+
+	s.accounts[strings.ToLower(args[1])] = true
+	s.logIn(c, append([]string{""}, args[1:]...))
+}
+
+func (s *Server) sendList(c *Conn) {
+	games := []bgammon.GameListing{}
+	for _, m := range s.matches {
+		games = append(games, m.listing())
+	}
+	c.Send(&bgammon.EventList{Games: games})
+}
+
+// createMatch handles the command: c <public|private password> <points> <name>
+func (s *Server) createMatch(c *Conn, args []string) {
+	if c.match != nil {
+		c.notice("Failed to create match: You are already in a match.")
+		return
+	}
+
+	var password string
+	if len(args) > 0 && strings.ToLower(args[0]) == "private" && len(args) > 1 {
+		password = args[1]
+		args = args[2:]
+	} else if len(args) > 0 {
+		args = args[1:]
+	}
+
+	points := 1
+	if len(args) > 0 {
+		p, err := strconv.Atoi(args[0])
+		if err == nil && p > 0 {
+			points = p
+			args = args[1:]
+		}
+	}
+
+	name := strings.Join(args, " ")
+	if name == "" {
+		name = fmt.Sprintf("%s's match", c.Name)
+	}
+
+	game := bgammon.NewGame()
+	game.Board = s.newBoard()
+	game.Points = points
+	game.DoubleValue = 1
+
+	m := &match{
+		id:       s.nextMatchID,
+		name:     name,
+		password: password,
+		game:     game,
+		server:   s,
+	}
+	s.nextMatchID++
+	s.matches = append(s.matches, m)
+
+	m.addPlayer(c)
+}
+
+// joinMatch handles the command: j <id> [password]
+func (s *Server) joinMatch(c *Conn, args []string) {
+	fail := func(reason string) {
+		c.Send(&bgammon.EventFailedJoin{Reason: reason})
+	}
+
+	if c.match != nil {
+		fail("You are already in a match.")
+		return
+	} else if len(args) == 0 {
+		fail("Specify the ID of the match.")
+		return
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fail("Invalid match ID.")
+		return
+	}
+
+	var m *match
+	for _, existing := range s.matches {
+		if existing.id == id {
+			m = existing
+			break
+		}
+	}
+	if m == nil {
+		fail("Match not found.")
+		return
+	} else if m.players[0] != nil && m.players[1] != nil {
+		fail("Match is full.")
+		return
+	} else if m.password != "" && (len(args) < 2 || args[1] != m.password) {
+		fail("Invalid password.")
+		return
+	}
+
+	m.addPlayer(c)
+}
+
+func (s *Server) leaveMatch(c *Conn) {
+	m := c.match
+	if m == nil {
+		return
+	}
+
+	ev := &bgammon.EventLeft{}
+	ev.Player = c.Name
+	m.send(ev)
+
+	m.players[c.number-1] = nil
+	if c.number == 1 {
+		m.game.Player1.Name = ""
+	} else {
+		m.game.Player2.Name = ""
+	}
+	c.match = nil
+	c.number = 0
+
+	if m.players[0] != nil || m.players[1] != nil {
+		return
+	}
+	for i, existing := range s.matches {
+		if existing == m {
+			s.matches = append(s.matches[:i], s.matches[i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *Server) roll(c *Conn) {
+	fail := func(reason string) {
+		c.Send(&bgammon.EventFailedRoll{Reason: reason})
+	}
+
+	m := c.match
+	if m == nil {
+		fail("You are not in a match.")
+		return
+	} else if m.players[0] == nil || m.players[1] == nil {
+		fail("Wait for another player to join.")
+		return
+	}
+	g := m.game
+	if g.Winner != 0 {
+		fail("The match has ended.")
+		return
+	}
+
+	if g.Turn == 0 {
+		// Roll to determine which player moves first.
+		if (c.number == 1 && g.Roll1 != 0) || (c.number == 2 && g.Roll2 != 0) {
+			fail("You have already rolled.")
+			return
+		}
+		if c.number == 1 {
+			g.Roll1 = s.rollDie()
+		} else {
+			g.Roll2 = s.rollDie()
+		}
+		m.sendRolled(c)
+
+		if g.Roll1 != 0 && g.Roll2 != 0 {
+			if g.Roll1 == g.Roll2 {
+				m.notice("Both players rolled the same value. Roll again.")
+				g.Roll1, g.Roll2 = 0, 0
+			} else {
+				g.Turn = 1
+				if g.Roll2 > g.Roll1 {
+					g.Turn = 2
+				}
+				g.Moves = nil
+			}
+		}
+		m.sendBoards()
+		return
+	}
+
+	if g.Turn != c.number {
+		fail("It is not your turn.")
+		return
+	} else if g.Roll1 != 0 {
+		fail("You have already rolled.")
+		return
+	} else if g.DoubleOffered {
+		fail("Respond to the offered double first.")
+		return
+	}
+
+	g.Roll1, g.Roll2 = s.rollDie(), s.rollDie()
+	g.Moves = nil
+	m.sendRolled(c)
+	m.sendBoards()
+}
+
+// move handles the command: mv <from>/<to> [<from>/<to>...]
+func (s *Server) move(c *Conn, args []string) {
+	fail := func(from int, to int, reason string) {
+		c.Send(&bgammon.EventFailedMove{From: from, To: to, Reason: reason})
+	}
+
+	m := c.match
+	if m == nil {
+		fail(0, 0, "You are not in a match.")
+		return
+	}
+	g := m.game
+	if g.Turn != c.number || g.Roll1 == 0 || g.Winner != 0 {
+		fail(0, 0, "It is not your turn to move.")
+		return
+	} else if len(args) == 0 {
+		fail(0, 0, "Specify the moves to make.")
+		return
+	}
+
+	var moves [][]int
+	for _, arg := range args {
+		split := strings.Split(arg, "/")
+		if len(split) != 2 {
+			fail(0, 0, fmt.Sprintf("Invalid move: %s", arg))
+			return
+		}
+		from, to := parseSpace(split[0], c.number), parseSpace(split[1], c.number)
+		if from == -1 || to == -1 {
+			fail(0, 0, fmt.Sprintf("Invalid move: %s", arg))
+			return
+		}
+		moves = append(moves, []int{from, to})
+	}
+
+	// Validate all moves before applying any of them.
+	next := g.Copy()
+	for _, move := range moves {
+		if ok, _ := next.AddMoves([][]int{move}, false); !ok {
+			fail(flipSpace(move[0], c.number), flipSpace(move[1], c.number), "Illegal move.")
+			return
+		}
+	}
+	*g = *next
+
+	for _, p := range m.players {
+		if p != nil {
+			ev := &bgammon.EventMoved{Moves: flipMoves(moves, p.number)}
+			ev.Player = c.Name
+			p.Send(ev)
+		}
+	}
+
+	if checkers(g.Board, homeSpace(c.number), c.number) == checkersPerPlayer {
+		m.win(c.number, g.DoubleValue)
+		return
+	}
+	m.sendBoards()
+}
+
+func (s *Server) ok(c *Conn) {
+	fail := func(reason string) {
+		c.Send(&bgammon.EventFailedOk{Reason: reason})
+	}
+
+	m := c.match
+	if m == nil {
+		fail("You are not in a match.")
+		return
+	}
+	g := m.game
+
+	// Accept the offered double.
+	if g.DoubleOffered && g.Turn != c.number {
+		g.DoubleOffered = false
+		g.DoubleValue *= 2
+		g.DoublePlayer = c.number
+		m.notice(fmt.Sprintf("%s accepted the double.", c.Name))
+		m.sendBoards()
+		return
+	}
+
+	if g.Turn != c.number || g.Roll1 == 0 {
+		fail("It is not your turn.")
+		return
+	} else if len(g.LegalMoves(false)) != 0 {
+		fail("You must use all of your available moves.")
+		return
+	}
+
+	g.Turn = opponent(c.number)
+	g.Roll1, g.Roll2 = 0, 0
+	g.Moves = nil
+	m.sendBoards()
+}
+
+func (s *Server) double(c *Conn) {
+	m := c.match
+	if m == nil {
+		c.notice("You are not in a match.")
+		return
+	}
+	g := m.game
+	if g.Turn != c.number || g.Roll1 != 0 || g.DoubleOffered || g.Winner != 0 || (g.DoublePlayer != 0 && g.DoublePlayer != c.number) {
+		c.notice("You may not double at this time.")
+		return
+	}
+
+	g.DoubleOffered = true
+	m.notice(fmt.Sprintf("%s offers a double.", c.Name))
+	m.sendBoards()
+}
+
+func (s *Server) resign(c *Conn) {
+	m := c.match
+	if m == nil {
+		c.notice("You are not in a match.")
+		return
+	}
+	g := m.game
+	if g.Winner != 0 {
+		c.notice("The match has ended.")
+		return
+	}
+
+	g.DoubleOffered = false
+	m.win(opponent(c.number), g.DoubleValue)
+}
+
+// parseSpace parses a space specified from the perspective of the player and
+// returns the space as stored by the server. Spaces may be specified as
+// numbers, or as bar or off.
+func parseSpace(space string, player int) int {
+	switch strings.ToLower(space) {
+	case "bar", "b":
+		return barSpace(player)
+	case "off", "o", "home", "h":
+		return homeSpace(player)
+	}
+	v, err := strconv.Atoi(space)
+	if err != nil || v < 0 || v >= bgammon.BoardSpaces {
+		return -1
+	}
+	return flipSpace(v, player)
+}
+
+// helpText lists the commands supported by the server, one per line. Of the
+// optional commands, only register and ping are supported.
+const helpText = `help - Print this help text.
+register <email> <username> <password> - Register an account.
+list - List matches.
+create <public|private password> <points> <name> - Create a match.
+join <id> [password] - Join a match.
+leave - Leave the current match.
+say <message> - Send a message to your opponent.
+board - Print the current board.
+roll - Roll the dice.
+move <from>/<to> [<from>/<to>...] - Move checkers.
+ok - Confirm your moves, or accept an offered double.
+double - Offer a double.
+resign - Resign the current game.
+ping <message> - Request a ping event containing the message.
+pong <message> - Respond to a ping event.`
+
+// Conn is a client connected to the server.
+type Conn struct {
+	// Name is the username of the client. It is empty until the client logs in.
+	Name string
+
+	conn   MessageConn
+	server *Server
+
+	match  *match
+	number int // Player number in the current match.
+
+	writeLock sync.Mutex
+}
+
+// Send sends an event to the client. The event type is set automatically.
+func (c *Conn) Send(ev interface{}) {
+	setEventType(ev)
+
+	buf, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("fakeserver: failed to encode event: %s", err)
+		return
+	}
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	err = c.conn.Write(buf)
+	if err != nil {
+		c.conn.Close()
+	}
+}
+
+func (c *Conn) notice(message string) {
+	c.Send(&bgammon.EventNotice{Message: message})
+}
+
+func setEventType(ev interface{}) {
+	switch ev := ev.(type) {
+	case *bgammon.EventWelcome:
+		ev.Type = bgammon.EventTypeWelcome
+	case *bgammon.EventHelp:
+		ev.Type = bgammon.EventTypeHelp
+	case *bgammon.EventPing:
+		ev.Type = bgammon.EventTypePing
+	case *bgammon.EventNotice:
+		ev.Type = bgammon.EventTypeNotice
+	case *bgammon.EventSay:
+		ev.Type = bgammon.EventTypeSay
+	case *bgammon.EventList:
+		ev.Type = bgammon.EventTypeList
+	case *bgammon.EventJoined:
+		ev.Type = bgammon.EventTypeJoined
+	case *bgammon.EventFailedJoin:
+		ev.Type = bgammon.EventTypeFailedJoin
+	case *bgammon.EventLeft:
+		ev.Type = bgammon.EventTypeLeft
+	case *bgammon.EventFailedLeave:
+		ev.Type = bgammon.EventTypeFailedLeave
+	case *bgammon.EventBoard:
+		ev.Type = bgammon.EventTypeBoard
+	case *bgammon.EventRolled:
+		ev.Type = bgammon.EventTypeRolled
+	case *bgammon.EventFailedRoll:
+		ev.Type = bgammon.EventTypeFailedRoll
+	case *bgammon.EventMoved:
+		ev.Type = bgammon.EventTypeMoved
+	case *bgammon.EventFailedMove:
+		ev.Type = bgammon.EventTypeFailedMove
+	case *bgammon.EventFailedOk:
+		ev.Type = bgammon.EventTypeFailedOk
+	case *bgammon.EventWin:
+		ev.Type = bgammon.EventTypeWin
+	}
+}
+
+type match struct {
+	id       int
+	name     string
+	password string
+
+	game    *bgammon.Game
+	players [2]*Conn
+	server  *Server
+}
+
+func (m *match) listing() bgammon.GameListing {
+	var players int
+	for _, p := range m.players {
+		if p != nil {
+			players++
+		}
+	}
+	return bgammon.GameListing{
+		ID:       m.id,
+		Password: m.password != "",
+		Points:   m.game.Points,
+		Players:  players,
+		Name:     m.name,
+	}
+}
+
+func (m *match) addPlayer(c *Conn) {
+	number := 1
+	if m.players[0] != nil {
+		number = 2
+	}
+	m.players[number-1] = c
+	c.match = m
+	c.number = number
+
+	player := bgammon.Player{Number: number, Name: c.Name}
+	if number == 1 {
+		player.Points = m.game.Player1.Points
+		m.game.Player1 = player
+	} else {
+		player.Points = m.game.Player2.Points
+		m.game.Player2 = player
+	}
+
+	// Inform the new player of the existing player.
+	opp := m.opponent(c)
+	if opp != nil {
+		ev := &bgammon.EventJoined{GameID: m.id, PlayerNumber: opp.number}
+		ev.Player = opp.Name
+		c.Send(ev)
+	}
+
+	ev := &bgammon.EventJoined{GameID: m.id, PlayerNumber: number}
+	ev.Player = c.Name
+	m.send(ev)
+
+	if m.players[0] != nil && m.players[1] != nil && m.game.Started.IsZero() {
+		m.game.Started = time.Now()
+	}
+	m.sendBoards()
+}
+
+func (m *match) opponent(c *Conn) *Conn {
+	if c.number == 0 {
+		return nil
+	}
+	return m.players[2-c.number]
+}
+
+func (m *match) send(ev interface{}) {
+	for _, p := range m.players {
+		if p != nil {
+			p.Send(ev)
+		}
+	}
+}
+
+func (m *match) notice(message string) {
+	m.send(&bgammon.EventNotice{Message: message})
+}
+
+func (m *match) sendRolled(c *Conn) {
+	ev := &bgammon.EventRolled{Roll1: m.game.Roll1, Roll2: m.game.Roll2}
+	ev.Player = c.Name
+	m.send(ev)
+}
+
+func (m *match) sendBoard(c *Conn) {
+	ev := &bgammon.EventBoard{
+		GameState: bgammon.GameState{
+			Game:         localGame(m.game, c.number),
+			PlayerNumber: c.number,
+		},
+	}
+	if m.game.Turn == c.number && m.game.Roll1 != 0 && m.game.Winner == 0 {
+		ev.Available = ev.Game.LegalMoves(true)
+	}
+	c.Send(ev)
+}
+
+func (m *match) sendBoards() {
+	for _, p := range m.players {
+		if p != nil {
+			m.sendBoard(p)
+		}
+	}
+}
+
+// win ends the current game. A new game is started when neither player has
+// won the match.
+func (m *match) win(player int, points int) {
+	g := m.game
+	g.Winner = player
+	g.Ended = time.Now()
+
+	winner := &g.Player1
+	if player == 2 {
+		winner = &g.Player2
+	}
+	winner.Points += points
+
+	ev := &bgammon.EventWin{Points: points}
+	ev.Player = winner.Name
+	m.send(ev)
+
+	if winner.Points < g.Points {
+		g.Board = m.server.newBoard()
+		g.Turn = 0
+		g.Roll1, g.Roll2 = 0, 0
+		g.Moves = nil
+		g.Winner = 0
+		g.Ended = time.Time{}
+		g.DoubleValue = 1
+		g.DoublePlayer = 0
+		g.DoubleOffered = false
+	}
+	m.sendBoards()
+}
+
+// lineConn is a MessageConn which reads and writes lines of text.
+type lineConn struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+func newLineConn(conn net.Conn) *lineConn {
+	return &lineConn{
+		conn:    conn,
+		scanner: bufio.NewScanner(conn),
+	}
+}
+
+func (c *lineConn) Read() ([]byte, error) {
+	if !c.scanner.Scan() {
+		err := c.scanner.Err()
+		if err == nil {
+			err = net.ErrClosed
+		}
+		return nil, err
+	}
+	return c.scanner.Bytes(), nil
+}
+
+func (c *lineConn) Write(message []byte) error {
+	_, err := c.conn.Write(append(message[:len(message):len(message)], '\n'))
+	return err
+}
+
+func (c *lineConn) Close() error {
+	return c.conn.Close()
+}
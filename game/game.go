@@ -3,6 +3,7 @@ package game
 import (
 	"bytes"
 	"embed"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -10,6 +11,7 @@ import (
 	"io"
 	"log"
 	"math/rand"
+	"net/url"
 	"os"
 	"path"
 	"regexp"
@@ -19,6 +21,8 @@ import (
 	"time"
 
 	"code.rocket9labs.com/tslocum/bgammon"
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
+	"code.rocket9labs.com/tslocum/boxcars/game/locales"
 	"code.rocket9labs.com/tslocum/etk"
 	"code.rocketnine.space/tslocum/kibodo"
 	"code.rocketnine.space/tslocum/messeji"
@@ -44,7 +48,7 @@ const baseButtonHeight = 56
 
 var onlyNumbers = regexp.MustCompile(`[0-9]+`)
 
-//go:embed asset locales
+//go:embed asset
 var assetFS embed.FS
 
 var debugExtra []byte
@@ -99,22 +103,24 @@ var (
 	statusBuffer      = etk.NewText("")
 	floatStatusBuffer = etk.NewText("")
 	gameBuffer        = etk.NewText("")
-	inputBuffer       = etk.NewInput("", "", acceptInput)
+	inputBuffer       *etk.Input
 
 	statusLogged bool
 	gameLogged   bool
 
 	lobbyStatusBufferHeight = 75
 
-	Debug int
-
 	game *Game
 
 	diceSize int
 
-	connectGrid    *etk.Grid
-	createGameGrid *etk.Grid
-	joinGameGrid   *etk.Grid
+	connectGrid       *etk.Grid
+	registerGrid      *etk.Grid
+	resetPasswordGrid *etk.Grid
+	offlineGrid       *etk.Grid
+	sessionGrid       *etk.Grid
+	createGameGrid    *etk.Grid
+	joinGameGrid      *etk.Grid
 
 	createGameContainer *etk.Grid
 	joinGameContainer   *etk.Grid
@@ -164,6 +170,8 @@ func lg(s string) {
 }
 
 func init() {
+	inputBuffer = etk.NewInput("", "", acceptInput)
+
 	gotext.SetDomain("boxcars")
 
 	initializeFonts()
@@ -420,12 +428,8 @@ func diceImage(roll int) *ebiten.Image {
 
 func setViewBoard(view bool) {
 	if viewBoard != view {
-		g := game
-		g.keyboard.Hide()
-		g.Board.floatChatGrid.SetVisible(false)
-		g.connectKeyboardButton.Label.SetText(gotext.Get("Show Keyboard"))
-		g.lobby.showKeyboardButton.Label.SetText(gotext.Get("Show Keyboard"))
-		g.Board.showKeyboardButton.Label.SetText(gotext.Get("Show Keyboard"))
+		game.hideKeyboard()
+		game.Board.floatChatGrid.SetVisible(false)
 	}
 
 	viewBoard = view
@@ -456,6 +460,8 @@ func setViewBoard(view bool) {
 
 		game.Board.menuGrid.SetVisible(false)
 		game.Board.settingsGrid.SetVisible(false)
+		game.Board.exportGrid.SetVisible(false)
+		game.Board.enterPositionGrid.SetVisible(false)
 		game.Board.leaveGameGrid.SetVisible(false)
 
 		statusBuffer.SetRect(statusBuffer.Rect())
@@ -507,16 +513,51 @@ type Game struct {
 	Username      string
 	Password      string
 	loggedIn      bool
+	reconnecting  bool
+	viewingMatch  bool // A match file is shown on the board.
+
+	matchID       int    // ID of the match being played.
+	matchPassword string // Password of the match being played.
+	resumeMatch   bool   // Rejoin the match after reconnecting.
 
 	Watch bool
 	TV    bool
 
-	Client *Client
+	watchPending bool // Watch matches once the capabilities of the server are known.
+
+	// AutoConnect connects without waiting for the user to select Connect.
+	AutoConnect bool
+
+	Client *client.Client
+
+	sessions     []*session // Connections to servers.
+	session      *session   // Active session.
+	sessionCount int32      // Number of sessions, which may be read without the lock.
+	sessionLock  sync.Mutex
+
+	// Transport is used to connect to the server instead of the transport
+	// selected based on ServerAddress, when set.
+	Transport client.Transport
+
+	// TLS configures connections to servers using the tls:// scheme.
+	TLS *client.TLSOptions
+
+	// Proxy is the proxy used to connect to the server.
+	Proxy *url.URL
+
+	// Record records the messages exchanged with the server when set.
+	Record *client.Recorder
+
+	// MatchDir is the directory matches are saved to. A directory in the
+	// home directory of the user is used when unset.
+	MatchDir string
 
 	Board *board
 
 	lobby *lobby
 
+	events *client.Dispatcher
+
 	volume float64 // Volume range is 0-1.
 
 	runeBuffer []rune
@@ -529,9 +570,36 @@ type Game struct {
 	cpuProfile *os.File
 
 	connectUsername       *etk.Input
-	connectPassword       *etk.Input
+	connectPassword       *passwordInput
+	connectInfo           *etk.Text
 	connectServer         *etk.Input
 	connectKeyboardButton *etk.Button
+	connectProfile        *etk.Button
+	connectRemember       *etk.Checkbox
+
+	config       *client.Config
+	profileIndex int // Index of the profile selected on the connect screen, or -1.
+
+	registerEmail    *etk.Input
+	registerUsername *etk.Input
+	registerPassword *passwordInput
+	registerConfirm  *passwordInput
+	registerInfo     *etk.Text
+
+	resetPasswordEmail *etk.Input
+	resetPasswordInfo  *etk.Text
+
+	offlineStrength *etk.Button
+	offlinePoints   *etk.Input
+
+	sessionPassword *passwordInput
+	sessionInfo     *etk.Text
+	sessionAddress  string // Address of the connection being added.
+	sessionUsername string // Username of the connection being added.
+
+	register   bool   // Register an account when connecting.
+	email      string // Email address used when registering.
+	lastNotice string // Last notice received from the server.
 
 	pressedKeys []ebiten.Key
 
@@ -566,11 +634,24 @@ func NewGame() *Game {
 	}
 	game = g
 
+	var err error
+	g.config, err = client.LoadConfig()
+	if err != nil {
+		log.Printf("error: failed to load configuration: %s", err)
+	}
+	g.profileIndex = -1
+
 	loadImageAssets(0)
 
 	g.Board = NewBoard()
 	g.lobby = NewLobby()
 
+	g.events = client.NewDispatcher()
+	g.subscribe(g.events)
+	g.lobby.subscribe(g.events)
+	g.Board.subscribe(g.events)
+	g.Board.subscribeHistory(g.events)
+
 	if AutoEnableTouchInput {
 		g.keyboard.SetKeys(kibodo.KeysMobileQWERTY)
 		g.keyboard.SetExtendedKeys(kibodo.KeysMobileSymbols)
@@ -602,7 +683,7 @@ func NewGame() *Game {
 			return nil
 		})
 
-		infoLabel := etk.NewText(gotext.Get("To log in as a guest, enter a username (if you want) and do not enter a password."))
+		g.connectInfo = etk.NewText(connectInfoText())
 
 		footerLabel := etk.NewText("Boxcars " + version)
 		footerLabel.SetHorizontal(messeji.AlignEnd)
@@ -612,9 +693,18 @@ func NewGame() *Game {
 			return false
 		})
 
-		g.connectPassword = etk.NewInput("", "", func(text string) (handled bool) {
-			return false
+		g.connectPassword = newPasswordInput()
+
+		profileLabel := etk.NewText(gotext.Get("Profile"))
+		g.connectProfile = etk.NewButton(gotext.Get("New profile"), g.selectNextProfile)
+		saveProfileButton := etk.NewButton(gotext.Get("Save profile"), g.selectSaveProfile)
+
+		rememberLabel := etk.NewText(gotext.Get("Save password"))
+		g.connectRemember = etk.NewCheckbox(func() error {
+			return nil
 		})
+		g.connectRemember.SetBorderColor(triangleA)
+		g.connectRemember.SetCheckColor(triangleA)
 
 		grid := etk.NewGrid()
 		grid.SetColumnPadding(int(g.Board.horizontalBorderSize / 2))
@@ -625,12 +715,12 @@ func NewGame() *Game {
 		grid.AddChildAt(nameLabel, 1, 1, 2, 1)
 		grid.AddChildAt(g.connectUsername, 2, 1, 2, 1)
 		grid.AddChildAt(passwordLabel, 1, 2, 2, 1)
-		grid.AddChildAt(g.connectPassword, 2, 2, 2, 1)
+		grid.AddChildAt(g.connectPassword.Input, 2, 2, 2, 1)
 		y := 3
 		if ShowServerSettings {
 			connectAddress := game.ServerAddress
 			if connectAddress == "" {
-				connectAddress = DefaultServerAddress
+				connectAddress = client.DefaultServerAddress
 			}
 			g.connectServer = etk.NewInput("", connectAddress, func(text string) (handled bool) {
 				return false
@@ -639,11 +729,116 @@ func NewGame() *Game {
 			grid.AddChildAt(g.connectServer, 2, y, 2, 1)
 			y++
 		}
-		grid.AddChildAt(infoLabel, 1, y, 3, 1)
+		grid.AddChildAt(profileLabel, 1, y, 1, 1)
+		grid.AddChildAt(g.connectProfile, 2, y, 1, 1)
+		grid.AddChildAt(saveProfileButton, 3, y, 1, 1)
+		y++
+		grid.AddChildAt(rememberLabel, 1, y, 1, 1)
+		{
+			rememberGrid := etk.NewGrid()
+			rememberGrid.SetColumnSizes(50, -1)
+			rememberGrid.AddChildAt(g.connectRemember, 0, 0, 1, 1)
+			grid.AddChildAt(rememberGrid, 2, y, 1, 1)
+		}
+		y++
+		grid.AddChildAt(g.connectInfo, 1, y, 3, 1)
+		if ShowMatchPicker {
+			grid.AddChildAt(etk.NewButton(gotext.Get("Open match"), g.selectOpenMatch), 1, y+1, 1, 1)
+		}
 		grid.AddChildAt(connectButton, 2, y+1, 1, 1)
 		grid.AddChildAt(g.connectKeyboardButton, 3, y+1, 1, 1)
-		grid.AddChildAt(footerLabel, 1, y+2, 3, 1)
+		grid.AddChildAt(etk.NewButton(gotext.Get("Play offline"), g.showOffline), 1, y+2, 1, 1)
+		grid.AddChildAt(etk.NewButton(gotext.Get("Register"), g.showRegister), 2, y+2, 1, 1)
+		grid.AddChildAt(etk.NewButton(gotext.Get("Forgot password"), g.showResetPassword), 3, y+2, 1, 1)
+		grid.AddChildAt(footerLabel, 1, y+3, 3, 1)
 		connectGrid = grid
+
+		if p := g.config.Profile(g.config.LastProfile); p != nil {
+			g.UseProfile(p.Name)
+			g.Username, g.Password = "", ""
+		}
+	}
+
+	{
+		newInput := func() *etk.Input {
+			return etk.NewInput("", "", func(text string) (handled bool) {
+				return false
+			})
+		}
+		g.registerEmail = newInput()
+		g.registerUsername = newInput()
+		g.registerPassword = newPasswordInput()
+		g.registerConfirm = newPasswordInput()
+		g.registerInfo = etk.NewText(gotext.Get("A confirmation email will be sent to the email address you enter."))
+
+		grid := etk.NewGrid()
+		grid.SetColumnPadding(int(g.Board.horizontalBorderSize / 2))
+		grid.SetRowPadding(20)
+		grid.SetColumnSizes(10, 200, -1, -1, 10)
+		grid.AddChildAt(etk.NewText(gotext.Get("Register")), 0, 0, 4, 1)
+		grid.AddChildAt(etk.NewText(gotext.Get("Email")), 1, 1, 1, 1)
+		grid.AddChildAt(g.registerEmail, 2, 1, 2, 1)
+		grid.AddChildAt(etk.NewText(gotext.Get("Username")), 1, 2, 1, 1)
+		grid.AddChildAt(g.registerUsername, 2, 2, 2, 1)
+		grid.AddChildAt(etk.NewText(gotext.Get("Password")), 1, 3, 1, 1)
+		grid.AddChildAt(g.registerPassword.Input, 2, 3, 2, 1)
+		grid.AddChildAt(etk.NewText(gotext.Get("Confirm password")), 1, 4, 1, 1)
+		grid.AddChildAt(g.registerConfirm.Input, 2, 4, 2, 1)
+		grid.AddChildAt(g.registerInfo, 1, 5, 3, 1)
+		grid.AddChildAt(etk.NewButton(gotext.Get("Register"), g.selectRegister), 2, 6, 1, 1)
+		grid.AddChildAt(etk.NewButton(gotext.Get("Cancel"), g.showConnect), 3, 6, 1, 1)
+		registerGrid = grid
+
+		g.resetPasswordEmail = newInput()
+		g.resetPasswordInfo = etk.NewText(gotext.Get("An email containing a link to reset your password will be sent to the email address associated with your account."))
+
+		grid = etk.NewGrid()
+		grid.SetColumnPadding(int(g.Board.horizontalBorderSize / 2))
+		grid.SetRowPadding(20)
+		grid.SetColumnSizes(10, 200, -1, -1, 10)
+		grid.AddChildAt(etk.NewText(gotext.Get("Reset password")), 0, 0, 4, 1)
+		grid.AddChildAt(etk.NewText(gotext.Get("Email")), 1, 1, 1, 1)
+		grid.AddChildAt(g.resetPasswordEmail, 2, 1, 2, 1)
+		grid.AddChildAt(g.resetPasswordInfo, 1, 2, 3, 1)
+		grid.AddChildAt(etk.NewButton(gotext.Get("Reset password"), g.selectResetPassword), 2, 3, 1, 1)
+		grid.AddChildAt(etk.NewButton(gotext.Get("Cancel"), g.showConnect), 3, 3, 1, 1)
+		resetPasswordGrid = grid
+
+		g.offlineStrength = etk.NewButton(g.config.OfflineStrength.String(), g.selectNextStrength)
+		g.offlinePoints = newInput()
+
+		grid = etk.NewGrid()
+		grid.SetColumnPadding(int(g.Board.horizontalBorderSize / 2))
+		grid.SetRowPadding(20)
+		grid.SetColumnSizes(10, 200, -1, -1, 10)
+		grid.AddChildAt(etk.NewText(gotext.Get("Play offline")), 0, 0, 4, 1)
+		grid.AddChildAt(etk.NewText(gotext.Get("Opponent")), 1, 1, 1, 1)
+		grid.AddChildAt(g.offlineStrength, 2, 1, 1, 1)
+		grid.AddChildAt(etk.NewText(gotext.Get("Points")), 1, 2, 1, 1)
+		grid.AddChildAt(g.offlinePoints, 2, 2, 2, 1)
+		grid.AddChildAt(etk.NewText(gotext.Get("Play a match against the computer without connecting to a server. Select the opponent to change its strength.")), 1, 3, 3, 1)
+		grid.AddChildAt(etk.NewButton(gotext.Get("Play"), g.selectPlayOffline), 2, 4, 1, 1)
+		grid.AddChildAt(etk.NewButton(gotext.Get("Cancel"), g.showConnect), 3, 4, 1, 1)
+		offlineGrid = grid
+
+		g.sessionPassword = newPasswordInput()
+		g.sessionPassword.Field.SetSelectedFunc(func() (accept bool) {
+			g.selectConnectSession()
+			return false
+		})
+		g.sessionInfo = etk.NewText("")
+
+		grid = etk.NewGrid()
+		grid.SetColumnPadding(int(g.Board.horizontalBorderSize / 2))
+		grid.SetRowPadding(20)
+		grid.SetColumnSizes(10, 200, -1, -1, 10)
+		grid.AddChildAt(etk.NewText(gotext.Get("Add connection")), 0, 0, 4, 1)
+		grid.AddChildAt(etk.NewText(gotext.Get("Password")), 1, 1, 1, 1)
+		grid.AddChildAt(g.sessionPassword.Input, 2, 1, 2, 1)
+		grid.AddChildAt(g.sessionInfo, 1, 2, 3, 1)
+		grid.AddChildAt(etk.NewButton(gotext.Get("Connect"), g.selectConnectSession), 2, 3, 1, 1)
+		grid.AddChildAt(etk.NewButton(gotext.Get("Cancel"), g.cancelConnectSession), 3, 3, 1, 1)
+		sessionGrid = grid
 	}
 
 	{
@@ -748,6 +943,8 @@ func NewGame() *Game {
 func (g *Game) handleUpdateTimeLabels() {
 	lastTimerHour, lastTimerMinute := -1, -1
 	lastClockHour, lastClockMinute := -1, -1
+	var lastLatency string
+	var lastQuality client.ConnectionQuality
 
 	t := time.NewTicker(3 * time.Second)
 	var now time.Time
@@ -786,6 +983,20 @@ func (g *Game) handleUpdateTimeLabels() {
 			scheduleFrame()
 		}
 
+		// Update connection quality indicator.
+		c := g.Client
+		latency, quality := latencyText(c), client.QualityUnknown
+		if c != nil {
+			quality = c.Quality()
+		}
+		if latency != lastLatency || quality != lastQuality {
+			g.Board.latencyLabel.SetText(latency)
+			g.Board.latencyLabel.SetForegroundColor(qualityColor(quality))
+			g.lobby.bufferDirty = true
+			lastLatency, lastQuality = latency, quality
+			scheduleFrame()
+		}
+
 		<-t.C
 	}
 }
@@ -813,148 +1024,289 @@ func (g *Game) handleAutoRefresh() {
 			continue
 		}
 
-		if g.Client != nil && g.Client.Username != "" {
-			g.Client.Out <- []byte("ls")
+		if g.Client != nil && g.Client.Connected() {
+			g.Client.List()
 			g.lastRefresh = time.Now()
 		}
 	}
 }
 
-func (g *Game) handleEvents() {
-	for e := range g.Client.Events {
-		switch ev := e.(type) {
-		case *bgammon.EventWelcome:
-			g.Client.Username = ev.PlayerName
+// Events returns the dispatcher which delivers the events received by the
+// active session.
+func (g *Game) Events() *client.Dispatcher {
+	return g.events
+}
 
-			areIs := "are"
-			if ev.Clients == 1 {
-				areIs = "is"
-			}
-			clientsPlural := "s"
-			if ev.Clients == 1 {
-				clientsPlural = ""
-			}
-			matchesPlural := "es"
-			if ev.Games == 1 {
-				matchesPlural = ""
-			}
-			l(fmt.Sprintf("*** Welcome, %s. There %s %d client%s playing %d match%s.", ev.PlayerName, areIs, ev.Clients, clientsPlural, ev.Games, matchesPlural))
-		case *bgammon.EventHelp:
-			l(fmt.Sprintf("*** Help: %s", ev.Message))
-		case *bgammon.EventNotice:
-			l(fmt.Sprintf("*** %s", ev.Message))
-		case *bgammon.EventSay:
-			l(fmt.Sprintf("<%s> %s", ev.Player, ev.Message))
-			playSoundEffect(effectSay)
-		case *bgammon.EventList:
-			g.lobby.setGameList(ev.Games)
-			if !viewBoard {
-				scheduleFrame()
-			}
-		case *bgammon.EventJoined:
-			g.Board.Lock()
-			if ev.PlayerNumber == 1 {
-				g.Board.gameState.Player1.Name = ev.Player
-			} else if ev.PlayerNumber == 2 {
-				g.Board.gameState.Player2.Name = ev.Player
-			}
-			g.Board.processState()
-			g.Board.Unlock()
-			setViewBoard(true)
+// subscribe subscribes the game to the events which are not displayed by the
+// lobby or the board.
+func (g *Game) subscribe(d *client.Dispatcher) {
+	for _, ev := range []interface{}{
+		(*bgammon.EventWelcome)(nil),
+		(*bgammon.EventHelp)(nil),
+		(*bgammon.EventNotice)(nil),
+		(*bgammon.EventJoined)(nil),
+		(*bgammon.EventFailedJoin)(nil),
+		(*bgammon.EventFailedLeave)(nil),
+		(*bgammon.EventLeft)(nil),
+		(*bgammon.EventFailedRoll)(nil),
+		(*bgammon.EventFailedMove)(nil),
+		(*bgammon.EventFailedOk)(nil),
+		(*bgammon.EventWin)(nil),
+		(*bgammon.EventPing)(nil),
+		(*client.EventConnectionState)(nil),
+		(*client.EventCapabilities)(nil),
+		(*client.EventLatencyWarning)(nil),
+		(*client.EventCommandFailed)(nil),
+	} {
+		d.Subscribe(ev, g.handleGameEvent)
+	}
+	d.Subscribe((*bgammon.EventSay)(nil), g.handleSay)
+}
+
+// handleEvent handles an event received by the active session.
+func (g *Game) handleEvent(e interface{}) {
+	if !g.events.Dispatch(e) && client.Debug > 0 {
+		l(fmt.Sprintf("*** Ignoring unsupported event: %+v", e))
+	}
+}
+
+func (g *Game) handleSay(e interface{}) {
+	ev := e.(*bgammon.EventSay)
+	l(fmt.Sprintf("<%s> %s", ev.Player, ev.Message))
+	playSoundEffect(effectSay)
+}
+
+func (g *Game) handleGameEvent(e interface{}) {
+	switch ev := e.(type) {
+	case *bgammon.EventWelcome:
+		areIs := "are"
+		if ev.Clients == 1 {
+			areIs = "is"
+		}
+		clientsPlural := "s"
+		if ev.Clients == 1 {
+			clientsPlural = ""
+		}
+		matchesPlural := "es"
+		if ev.Games == 1 {
+			matchesPlural = ""
+		}
+		l(fmt.Sprintf("*** Welcome, %s. There %s %d client%s playing %d match%s.", ev.PlayerName, areIs, ev.Clients, clientsPlural, ev.Games, matchesPlural))
+	case *bgammon.EventHelp:
+		l(fmt.Sprintf("*** Help: %s", ev.Message))
+	case *bgammon.EventNotice:
+		g.lastNotice = ev.Message
+		l(fmt.Sprintf("*** %s", ev.Message))
+	case *bgammon.EventJoined:
+		g.Board.Lock()
+		if ev.PlayerNumber == 1 {
+			g.Board.gameState.Player1.Name = ev.Player
+		} else if ev.PlayerNumber == 2 {
+			g.Board.gameState.Player2.Name = ev.Player
+		}
+		g.Board.processState()
+		g.Board.Unlock()
+		setViewBoard(true)
 
-			if ev.Player == g.Client.Username {
+		if ev.Player == g.Client.PlayerName() {
+			if g.resumeMatch && ev.GameID == g.matchID {
+				lg(gotext.Get("Rejoined the match."))
+			} else {
 				gameBuffer.SetText("")
 				gameLogged = false
-			} else {
-				lg(gotext.Get("%s joined the match.", ev.Player))
-				playSoundEffect(effectJoinLeave)
 			}
-		case *bgammon.EventFailedJoin:
-			l("*** " + gotext.Get("Failed to join match: %s", ev.Reason))
-		case *bgammon.EventFailedLeave:
-			l("*** " + gotext.Get("Failed to leave match: %s", ev.Reason))
-			setViewBoard(false)
-		case *bgammon.EventLeft:
-			g.Board.Lock()
-			if g.Board.gameState.Player1.Name == ev.Player {
-				g.Board.gameState.Player1.Name = ""
-			} else if g.Board.gameState.Player2.Name == ev.Player {
-				g.Board.gameState.Player2.Name = ""
-			}
-			g.Board.processState()
-			g.Board.Unlock()
-			if ev.Player == g.Client.Username {
-				setViewBoard(false)
-			} else {
-				lg(gotext.Get("%s left the match.", ev.Player))
-				playSoundEffect(effectJoinLeave)
-			}
-		case *bgammon.EventBoard:
-			g.Board.Lock()
-			*g.Board.gameState = ev.GameState
-			*g.Board.gameState.Game = *ev.GameState.Game
-			g.Board.processState()
-			g.Board.Unlock()
-			setViewBoard(true)
-		case *bgammon.EventRolled:
+			g.matchID = ev.GameID
+			g.resumeMatch = false
+		} else {
+			lg(gotext.Get("%s joined the match.", ev.Player))
+			playSoundEffect(effectJoinLeave)
+		}
+	case *bgammon.EventFailedJoin:
+		if g.resumeMatch {
+			g.resumeMatch = false
+			g.matchID = 0
+			l("*** " + gotext.Get("Failed to rejoin match: %s", ev.Reason))
 			g.Board.Lock()
-			g.Board.gameState.Roll1 = ev.Roll1
-			g.Board.gameState.Roll2 = ev.Roll2
-			var diceFormatted string
-			if g.Board.gameState.Turn == 0 {
-				if g.Board.gameState.Player1.Name == ev.Player {
-					diceFormatted = fmt.Sprintf("%d", g.Board.gameState.Roll1)
-				} else {
-					diceFormatted = fmt.Sprintf("%d", g.Board.gameState.Roll2)
-				}
-				playSoundEffect(effectDie)
-			} else {
-				diceFormatted = fmt.Sprintf("%d-%d", g.Board.gameState.Roll1, g.Board.gameState.Roll2)
-				playSoundEffect(effectDice)
+			g.Board.gameState = &bgammon.GameState{
+				Game: bgammon.NewGame(),
 			}
 			g.Board.processState()
 			g.Board.Unlock()
-			scheduleFrame()
-			lg(gotext.Get("%s rolled %s.", ev.Player, diceFormatted))
-		case *bgammon.EventFailedRoll:
-			l(fmt.Sprintf("*** Failed to roll: %s", ev.Reason))
-		case *bgammon.EventMoved:
-			lg(gotext.Get("%s moved %s.", ev.Player, bgammon.FormatMoves(ev.Moves)))
-			playSoundEffect(effectMove)
-			if ev.Player == g.Client.Username {
-				continue
-			}
-			g.Board.Lock()
-			for _, move := range ev.Moves {
-				g.Board.movePiece(move[0], move[1])
-			}
-			g.Board.Unlock()
-		case *bgammon.EventFailedMove:
-			g.Client.Out <- []byte("board") // Refresh game state.
+			setViewBoard(false)
+			return
+		}
+		l("*** " + gotext.Get("Failed to join match: %s", ev.Reason))
+	case *bgammon.EventFailedLeave:
+		l("*** " + gotext.Get("Failed to leave match: %s", ev.Reason))
+		setViewBoard(false)
+	case *bgammon.EventLeft:
+		g.Board.Lock()
+		if g.Board.gameState.Player1.Name == ev.Player {
+			g.Board.gameState.Player1.Name = ""
+		} else if g.Board.gameState.Player2.Name == ev.Player {
+			g.Board.gameState.Player2.Name = ""
+		}
+		g.Board.processState()
+		g.Board.Unlock()
+		if ev.Player == g.Client.PlayerName() {
+			g.matchID = 0
+			g.matchPassword = ""
+			setViewBoard(false)
+		} else {
+			lg(gotext.Get("%s left the match.", ev.Player))
+			playSoundEffect(effectJoinLeave)
+		}
+	case *bgammon.EventFailedRoll:
+		l(fmt.Sprintf("*** Failed to roll: %s", ev.Reason))
+	case *bgammon.EventFailedMove:
+		g.Client.Board() // Refresh game state.
+
+		var extra string
+		if ev.From != 0 || ev.To != 0 {
+			extra = fmt.Sprintf(" from %s to %s", bgammon.FormatSpace(ev.From), bgammon.FormatSpace(ev.To))
+		}
+		l("*** " + gotext.Get("Failed to move checker%s: %s", extra, ev.Reason))
+		l("*** " + gotext.Get("Legal moves: %s", bgammon.FormatMoves(g.Board.gameState.Available)))
+	case *bgammon.EventFailedOk:
+		g.Client.Board() // Refresh game state.
+		l("*** " + gotext.Get("Failed to submit moves: %s", ev.Reason))
+	case *bgammon.EventWin:
+		g.Board.Lock()
+		lg(gotext.Get("%s wins!", ev.Player))
+		if (g.Board.gameState.Player1.Points >= g.Board.gameState.Points || g.Board.gameState.Player2.Points >= g.Board.gameState.Points) && g.Client.HasCapability(client.CapabilityRematch) {
+			lg(gotext.Get("Type %s to offer a rematch.", "/rematch"))
+		}
+		g.Board.Unlock()
+	case *bgammon.EventPing:
+		g.Client.Pong(ev.Message)
+	case *client.EventConnectionState:
+		g.handleConnectionState(ev)
+	case *client.EventCapabilities:
+		if client.Debug > 0 {
+			l(fmt.Sprintf("*** Server capabilities: %s", strings.Join(ev.Capabilities, ", ")))
+		}
+		if g.watchPending {
+			g.watchPending = false
+			g.startWatching()
+		}
+	case *client.EventCommandFailed:
+		l("*** " + gotext.Get("Failed to send command %s: %s", ev.Command, ev.Err))
+	case *client.EventLatencyWarning:
+		l("*** " + gotext.Get("Warning: The connection to the server is slow (%s, usually %s). Moves may be delayed.", client.FormatLatency(ev.Latency), client.FormatLatency(ev.Average)))
+	}
+}
 
-			var extra string
-			if ev.From != 0 || ev.To != 0 {
-				extra = fmt.Sprintf(" from %s to %s", bgammon.FormatSpace(ev.From), bgammon.FormatSpace(ev.To))
-			}
-			l("*** " + gotext.Get("Failed to move checker%s: %s", extra, ev.Reason))
-			l("*** " + gotext.Get("Legal moves: %s", bgammon.FormatMoves(g.Board.gameState.Available)))
-		case *bgammon.EventFailedOk:
-			g.Client.Out <- []byte("board") // Refresh game state.
-			l("*** " + gotext.Get("Failed to submit moves: %s", ev.Reason))
-		case *bgammon.EventWin:
+func (g *Game) handleConnectionState(ev *client.EventConnectionState) {
+	switch ev.State {
+	case client.StateConnected:
+		if g.reconnecting {
+			l("*** " + gotext.Get("Reconnected."))
+			g.resumeAfterReconnect()
+		}
+		g.reconnecting = false
+	case client.StateBackoff:
+		var proxyErr *client.ProxyError
+		if client.IsCertificateError(ev.Err) {
+			l("*** " + gotext.Get("Failed to verify the certificate of the server: %s", ev.Err))
+		} else if errors.As(ev.Err, &proxyErr) {
+			l("*** " + gotext.Get("Failed to connect via proxy: %s", proxyErr))
+		}
+		if !g.reconnecting {
+			l("*** " + gotext.Get("Connection lost."))
+
+			// Remember the match being played so it may be rejoined.
 			g.Board.Lock()
-			lg(gotext.Get("%s wins!", ev.Player))
-			if g.Board.gameState.Player1.Points >= g.Board.gameState.Points || g.Board.gameState.Player2.Points >= g.Board.gameState.Points {
-				lg(gotext.Get("Type %s to offer a rematch.", "/rematch"))
-			}
+			g.resumeMatch = viewBoard && g.matchID != 0 && g.Board.playingGame() && g.Board.gameState.Winner == 0
+			g.Board.dragging = nil
+			g.Board.bearOffOverlay.SetVisible(false)
 			g.Board.Unlock()
-		case *bgammon.EventPing:
-			g.Client.Out <- []byte(fmt.Sprintf("pong %s", ev.Message))
-		default:
-			l("*** " + gotext.Get("Warning: Received unknown event: %+v", ev))
-			l("*** " + gotext.Get("You may need to upgrade your client.", ev))
 		}
+		g.reconnecting = true
+		delay := time.Until(ev.Retry).Round(time.Second)
+		l("*** " + gotext.Get("Reconnecting in %s...", delay))
+	case client.StateDisconnected:
+		if errors.Is(ev.Err, client.ErrRegistrationFailed) {
+			// Handled by handleEvents once the session lock is released.
+			return
+		}
+		l("*** " + gotext.Get("Disconnected."))
+	}
+
+	g.lobby.bufferDirty = true
+	g.Board.Lock()
+	g.Board.processState()
+	g.Board.Unlock()
+	scheduleFrame()
+}
+
+// connectionStatusText returns a description of the connection state of the
+// provided client.
+func connectionStatusText(c *client.Client) string {
+	switch c.State() {
+	case client.StateBackoff:
+		retry := time.Until(c.RetryAt()).Round(time.Second)
+		if retry < time.Second {
+			return gotext.Get("Reconnecting...")
+		}
+		return gotext.Get("Connection lost. Reconnecting in %s...", retry)
+	case client.StateDisconnected:
+		return gotext.Get("Disconnected.")
+	default:
+		return gotext.Get("Connecting...")
+	}
+}
+
+// resumeAfterReconnect restores the match or broadcast which was being viewed
+// before the connection was lost.
+func (g *Game) resumeAfterReconnect() {
+	c := g.Client
+	if g.resumeMatch {
+		l("*** " + gotext.Get("Rejoining match..."))
+		c.Join(g.matchID, g.matchPassword)
+	} else if g.TV {
+		g.watchPending = true
+	}
+}
+
+// startWatching watches matches as requested via the -tv or -watch options,
+// once the capabilities of the server are known.
+func (g *Game) startWatching() {
+	c := g.Client
+	if g.TV {
+		if !c.HasCapability(client.CapabilityTV) {
+			l("*** " + gotext.Get("The server does not support watching matches continuously."))
+			return
+		}
+		c.TV()
+	} else if g.Watch {
+		if !c.HasCapability(client.CapabilityWatch) {
+			l("*** " + gotext.Get("The server does not support watching matches."))
+			return
+		}
+		c.Watch(0)
+	}
+}
+
+// newClient returns a client configured to connect to the specified server.
+func (g *Game) newClient(address string, username string, password string) *client.Client {
+	if address == "" {
+		address = client.DefaultServerAddress
+	}
+	c := client.NewClient(address, username, password)
+	if g.Transport != nil {
+		// Recordings may only be replayed once, so the same transport is
+		// used for every connection attempt.
+		t := g.Transport
+		c.NewTransport = func() client.Transport {
+			return t
+		}
+	}
+	c.TLS = g.TLS
+	c.Proxy = g.Proxy
+	if g.Record != nil {
+		c.Record(g.Record)
 	}
+	return c
 }
 
 func (g *Game) Connect() {
@@ -965,67 +1317,307 @@ func (g *Game) Connect() {
 
 	l("*** " + gotext.Get("Connecting..."))
 
-	g.keyboard.Hide()
-	g.connectKeyboardButton.Label.SetText(gotext.Get("Show Keyboard"))
-	g.lobby.showKeyboardButton.Label.SetText(gotext.Get("Show Keyboard"))
-	g.Board.showKeyboardButton.Label.SetText(gotext.Get("Show Keyboard"))
-
+	g.hideKeyboard()
 	g.setRoot(listGamesFrame)
 
-	address := g.ServerAddress
-	if address == "" {
-		address = DefaultServerAddress
-	}
-	g.Client = newClient(address, g.Username, g.Password)
-	g.lobby.c = g.Client
-	g.Board.Client = g.Client
+	c := g.addSession(g.ServerAddress, g.Username, g.Password).client
+	c.Email = g.email
+	c.Register = g.register
 
 	g.Username = ""
 	g.Password = ""
+	g.email = ""
+	g.register = false
 
-	go g.handleEvents()
-
-	c := g.Client
-
-	if g.TV {
-		go func() {
-			time.Sleep(time.Second)
-			c.Out <- []byte("tv")
-		}()
-	} else if g.Watch {
-		go func() {
-			time.Sleep(time.Second)
-			c.Out <- []byte("watch")
-		}()
-	}
+	g.watchPending = g.TV || g.Watch
 
 	go c.Connect()
 }
 
+// hideKeyboard hides the virtual keyboard.
+func (g *Game) hideKeyboard() {
+	g.keyboard.Hide()
+	g.connectKeyboardButton.Label.SetText(gotext.Get("Show Keyboard"))
+	g.lobby.showKeyboardButton.Label.SetText(gotext.Get("Show Keyboard"))
+	g.Board.showKeyboardButton.Label.SetText(gotext.Get("Show Keyboard"))
+}
+
 func (g *Game) selectConnect() error {
 	g.Username = g.connectUsername.Text()
 	g.Password = g.connectPassword.Text()
 	if ShowServerSettings {
 		g.ServerAddress = g.connectServer.Text()
 	}
+	if g.profileIndex >= 0 && g.profileIndex < len(g.config.Profiles) {
+		g.config.LastProfile = g.config.Profiles[g.profileIndex].Name
+		err := g.config.Save()
+		if err != nil {
+			log.Printf("error: failed to save configuration: %s", err)
+		}
+	}
 	g.Connect()
 	return nil
 }
 
+// connectInfoText returns the text shown below the connection settings.
+func connectInfoText() string {
+	return gotext.Get("To log in as a guest, enter a username (if you want) and do not enter a password.")
+}
+
+func (g *Game) showConnect() error {
+	g.connectInfo.SetText(connectInfoText())
+	g.setRoot(connectGrid)
+	etk.SetFocus(g.connectUsername)
+	return nil
+}
+
+func (g *Game) showRegister() error {
+	g.registerEmail.Field.SetText("")
+	g.registerUsername.Field.SetText(g.connectUsername.Text())
+	g.registerPassword.SetText("")
+	g.registerConfirm.SetText("")
+	g.registerInfo.SetText(gotext.Get("A confirmation email will be sent to the email address you enter."))
+	g.setRoot(registerGrid)
+	etk.SetFocus(g.registerEmail)
+	return nil
+}
+
+func (g *Game) showResetPassword() error {
+	g.resetPasswordEmail.Field.SetText("")
+	g.resetPasswordInfo.SetText(gotext.Get("An email containing a link to reset your password will be sent to the email address associated with your account."))
+	g.setRoot(resetPasswordGrid)
+	etk.SetFocus(g.resetPasswordEmail)
+	return nil
+}
+
+// connectAddress returns the server address entered on the connect screen.
+func (g *Game) connectAddress() string {
+	if ShowServerSettings {
+		return strings.TrimSpace(g.connectServer.Text())
+	}
+	return g.ServerAddress
+}
+
+func (g *Game) selectRegister() error {
+	email := strings.TrimSpace(g.registerEmail.Text())
+	username := strings.TrimSpace(g.registerUsername.Text())
+	password := g.registerPassword.Text()
+	switch {
+	case !strings.Contains(email, "@"):
+		g.registerInfo.SetText(gotext.Get("Please enter a valid email address."))
+	case username == "":
+		g.registerInfo.SetText(gotext.Get("Please enter a username."))
+	case strings.ContainsAny(username, " \t"):
+		g.registerInfo.SetText(gotext.Get("Usernames may not contain spaces."))
+	case password == "":
+		g.registerInfo.SetText(gotext.Get("Please enter a password."))
+	case password != g.registerConfirm.Text():
+		g.registerInfo.SetText(gotext.Get("The passwords you entered do not match."))
+	default:
+		g.ServerAddress = g.connectAddress()
+		g.Username = username
+		g.Password = password
+		g.email = email
+		g.register = true
+		g.lastNotice = ""
+		g.Connect()
+		return nil
+	}
+	scheduleFrame()
+	return nil
+}
+
+// registrationFailed removes the session which failed to register an account
+// after the server closed its connection. Other sessions remain connected.
+// When no sessions remain, the registration screen is shown again. The
+// session lock must not be held.
+func (g *Game) registrationFailed(s *session, err error) {
+	reason := g.lastNotice
+	if reason == "" {
+		reason = err.Error()
+	}
+
+	g.sessionLock.Lock()
+	previous := g.removeSession(s)
+	if previous == nil {
+		g.session = nil
+	}
+	g.sessionLock.Unlock()
+
+	if previous != nil {
+		g.switchSession(previous)
+		g.lobby.rebuildButtonsGrid()
+		l("*** " + gotext.Get("Failed to register: %s", reason))
+		return
+	}
+	g.loggedIn = false
+
+	g.registerInfo.SetText(gotext.Get("Failed to register: %s", reason))
+	g.setRoot(registerGrid)
+	scheduleFrame()
+}
+
+func (g *Game) selectResetPassword() error {
+	email := strings.TrimSpace(g.resetPasswordEmail.Text())
+	if !strings.Contains(email, "@") {
+		g.resetPasswordInfo.SetText(gotext.Get("Please enter a valid email address."))
+		return nil
+	}
+	g.resetPasswordInfo.SetText(gotext.Get("Sending request..."))
+
+	c := g.newClient(g.connectAddress(), "", "")
+	go func() {
+		message, err := c.ResetPassword(email)
+		if err != nil {
+			g.resetPasswordInfo.SetText(gotext.Get("Failed to reset password: %s", err))
+		} else {
+			g.resetPasswordInfo.SetText(message)
+		}
+		scheduleFrame()
+	}()
+	return nil
+}
+
+// UseProfile fills the connection settings using the specified profile.
+func (g *Game) UseProfile(name string) error {
+	for i, p := range g.config.Profiles {
+		if p.Name == name {
+			g.setProfile(i)
+			g.Username = p.Username
+			g.Password = p.GetPassword()
+			g.ServerAddress = p.Server
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown profile: %s", name)
+}
+
+// setProfile selects a profile on the connect screen. When index is -1, the
+// fields are cleared to create a new profile.
+func (g *Game) setProfile(index int) {
+	g.profileIndex = index
+	if index < 0 || index >= len(g.config.Profiles) {
+		g.profileIndex = -1
+		g.connectProfile.Label.SetText(gotext.Get("New profile"))
+		g.connectUsername.Field.SetText("")
+		g.connectPassword.SetText("")
+		g.connectRemember.SetSelected(false)
+		if ShowServerSettings {
+			g.connectServer.Field.SetText(client.DefaultServerAddress)
+		}
+		return
+	}
+
+	p := g.config.Profiles[index]
+	g.connectProfile.Label.SetText(p.Name)
+	g.connectUsername.Field.SetText(p.Username)
+	password := p.GetPassword()
+	g.connectPassword.SetText(password)
+	g.connectRemember.SetSelected(password != "")
+	server := p.Server
+	if server == "" {
+		server = client.DefaultServerAddress
+	}
+	if ShowServerSettings {
+		g.connectServer.Field.SetText(server)
+	} else {
+		g.ServerAddress = server
+	}
+}
+
+func (g *Game) selectNextProfile() error {
+	index := g.profileIndex + 1
+	if index >= len(g.config.Profiles) {
+		index = -1
+	}
+	g.setProfile(index)
+	return nil
+}
+
+func (g *Game) selectSaveProfile() error {
+	p := &client.Profile{
+		Username: strings.TrimSpace(g.connectUsername.Text()),
+		Server:   g.ServerAddress,
+	}
+	if ShowServerSettings {
+		p.Server = strings.TrimSpace(g.connectServer.Text())
+	}
+
+	if g.profileIndex >= 0 && g.profileIndex < len(g.config.Profiles) {
+		existing := g.config.Profiles[g.profileIndex]
+		p.Name = existing.Name
+		p.Locale = existing.Locale
+	} else {
+		p.Locale = forcedLocale
+		p.Name = p.Username
+		if p.Name == "" {
+			p.Name = gotext.Get("Guest")
+		}
+		if p.Server != "" && p.Server != client.DefaultServerAddress {
+			p.Name += "@" + p.Server
+		}
+	}
+	if existing := g.config.Profile(p.Name); existing != nil {
+		existing.SetPassword("")
+	}
+	info := connectInfoText()
+	if g.connectRemember.Selected() {
+		err := p.SetPassword(g.connectPassword.Text())
+		if errors.Is(err, client.ErrKeyringUnavailable) {
+			info = gotext.Get("The password was not saved, as no keyring is available.")
+		} else if err != nil {
+			log.Printf("warning: failed to save password to keyring: %s", err)
+			info = gotext.Get("Failed to save password: %s", err)
+		}
+	}
+	g.connectInfo.SetText(info)
+	g.config.SetProfile(p)
+
+	err := g.config.Save()
+	if err != nil {
+		log.Printf("error: failed to save configuration: %s", err)
+		return nil
+	}
+	for i := range g.config.Profiles {
+		if g.config.Profiles[i] == p {
+			g.setProfile(i)
+			break
+		}
+	}
+	return nil
+}
+
 func (g *Game) handleInput(keys []ebiten.Key) error {
-	if !g.loggedIn {
+	if !g.loggedIn && !g.viewingMatch {
 		for _, key := range keys {
 			switch key {
 			case ebiten.KeyTab:
 				focusedWidget := etk.Focused()
 				switch focusedWidget {
 				case g.connectUsername:
-					etk.SetFocus(g.connectPassword)
-				case g.connectPassword:
+					etk.SetFocus(g.connectPassword.Input)
+				case g.connectPassword.Input:
 					etk.SetFocus(g.connectUsername)
+				case g.registerEmail:
+					etk.SetFocus(g.registerUsername)
+				case g.registerUsername:
+					etk.SetFocus(g.registerPassword.Input)
+				case g.registerPassword.Input:
+					etk.SetFocus(g.registerConfirm.Input)
+				case g.registerConfirm.Input:
+					etk.SetFocus(g.registerEmail)
 				}
 			case ebiten.KeyEnter, ebiten.KeyKPEnter:
-				g.selectConnect()
+				switch g.rootWidget {
+				case registerGrid:
+					g.selectRegister()
+				case resetPasswordGrid:
+					g.selectResetPassword()
+				case offlineGrid:
+					g.selectPlayOffline()
+				default:
+					g.selectConnect()
+				}
 			}
 		}
 		return nil
@@ -1039,10 +1631,14 @@ func (g *Game) handleInput(keys []ebiten.Key) error {
 					g.Board.menuGrid.SetVisible(false)
 				} else if g.Board.settingsGrid.Visible() {
 					g.Board.settingsGrid.SetVisible(false)
+				} else if g.Board.exportGrid.Visible() {
+					g.Board.exportGrid.SetVisible(false)
+				} else if g.Board.enterPositionGrid.Visible() {
+					g.Board.hideMenu()
 				} else if g.Board.leaveGameGrid.Visible() {
 					g.Board.leaveGameGrid.SetVisible(false)
 				} else {
-					g.Board.menuGrid.SetVisible(true)
+					g.Board.toggleMenu()
 				}
 				continue
 			}
@@ -1156,18 +1752,28 @@ func (g *Game) Update() error {
 			}
 		}
 		updateButtons(connectGrid)
+		updateButtons(registerGrid)
+		updateButtons(resetPasswordGrid)
+		updateButtons(offlineGrid)
+		updateButtons(sessionGrid)
 		updateButtons(game.lobby.buttonsGrid)
 		updateButtons(game.Board.menuGrid)
 		updateButtons(game.Board.leaveGameGrid)
 		updateButtons(game.Board.bearOffOverlay)
+		updateButtons(game.Board.connectionOverlay)
 		updateButtons(game.Board.floatChatGrid)
 
 		// Auto-connect
-		if g.Username != "" || g.Password != "" {
+		_, replay := g.Transport.(*client.ReplayTransport)
+		if !g.viewingMatch && (g.Username != "" || g.Password != "" || g.AutoConnect || replay) {
 			g.Connect()
 		}
 	}
 
+	if files := ebiten.DroppedFiles(); files != nil {
+		g.openDroppedFiles(files)
+	}
+
 	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyP) {
 		err := g.toggleProfiling()
 		if err != nil {
@@ -1176,12 +1782,12 @@ func (g *Game) Update() error {
 	}
 
 	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyD) {
-		Debug++
-		if Debug > MaxDebug {
-			Debug = 0
+		client.Debug++
+		if client.Debug > MaxDebug {
+			client.Debug = 0
 		}
-		g.Board.debug = Debug
-		etk.SetDebug(Debug == 2)
+		g.Board.debug = client.Debug
+		etk.SetDebug(client.Debug == 2)
 	}
 
 	// Handle physical keyboard.
@@ -1239,7 +1845,7 @@ func (g *Game) Update() error {
 		g.skipUpdate = false
 	}
 
-	if !g.loggedIn {
+	if !g.loggedIn && !g.viewingMatch {
 		if len(g.keyboardInput) > 0 {
 			w := etk.Focused()
 			if w != nil {
@@ -1302,11 +1908,15 @@ func (g *Game) Update() error {
 	} else {
 		g.Board.Update()
 
+		w := etk.Widget(inputBuffer)
+		if g.Board.enterPositionGrid.Visible() && etk.Focused() != nil {
+			w = etk.Focused()
+		}
 		for _, event := range g.keyboardInput {
 			if event.Rune > 0 {
-				inputBuffer.HandleKeyboard(-1, event.Rune)
+				w.HandleKeyboard(-1, event.Rune)
 			} else {
-				inputBuffer.HandleKeyboard(event.Key, 0)
+				w.HandleKeyboard(event.Key, 0)
 			}
 		}
 	}
@@ -1323,6 +1933,7 @@ func (g *Game) Update() error {
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
+	countFrame(!OptimizeDraw || drawScreen)
 	if OptimizeDraw {
 		if !drawScreen {
 			return
@@ -1334,7 +1945,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	screen.Fill(tableColor)
 
 	// Log in screen
-	if !g.loggedIn {
+	if !g.loggedIn && !g.viewingMatch {
 		err := etk.Draw(screen)
 		if err != nil {
 			log.Fatal(err)
@@ -1358,7 +1969,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 
 	game.keyboard.Draw(screen)
 
-	if Debug > 0 {
+	if client.Debug > 0 {
 		g.drawBuffer.Reset()
 
 		g.spinnerIndex++
@@ -1425,10 +2036,14 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 	inputBuffer.Field.SetScrollBarColors(etk.Style.ScrollAreaColor, etk.Style.ScrollHandleColor)
 
 	if ShowServerSettings {
-		connectGrid.SetRowSizes(60, 50, 50, 50, 108, g.scale(baseButtonHeight))
+		connectGrid.SetRowSizes(60, 50, 50, 50, 50, 50, 108, g.scale(baseButtonHeight), g.scale(baseButtonHeight))
 	} else {
-		connectGrid.SetRowSizes(60, 50, 50, 108, g.scale(baseButtonHeight))
+		connectGrid.SetRowSizes(60, 50, 50, 50, 50, 108, g.scale(baseButtonHeight), g.scale(baseButtonHeight))
 	}
+	registerGrid.SetRowSizes(60, 50, 50, 50, 50, 108, g.scale(baseButtonHeight))
+	resetPasswordGrid.SetRowSizes(60, 50, 108, g.scale(baseButtonHeight))
+	offlineGrid.SetRowSizes(60, 50, 50, 108, g.scale(baseButtonHeight))
+	sessionGrid.SetRowSizes(60, 50, 108, g.scale(baseButtonHeight))
 
 	{
 		scrollBarWidth := g.scale(32)
@@ -1537,14 +2152,22 @@ func acceptInput(text string) (handled bool) {
 		return true
 	}
 
+	if !game.loggedIn {
+		l("*** " + gotext.Get("Not connected to a server."))
+		return true
+	}
+
 	if text[0] == '/' {
-		text = text[1:]
-	} else {
-		l(fmt.Sprintf("<%s> %s", game.Client.Username, text))
-		text = "say " + text
+		if strings.EqualFold(text[1:], "rawevents") {
+			game.showRawEvents()
+		} else if !game.handleSessionCommand(text[1:]) {
+			game.Client.Send(text[1:])
+		}
+		return true
 	}
 
-	game.Client.Out <- []byte(text)
+	l(fmt.Sprintf("<%s> %s", game.Client.PlayerName(), text))
+	game.Client.Say(text)
 	return true
 }
 
@@ -1564,6 +2187,7 @@ func (g *Game) EnableTouchInput() {
 	*b.matchStatusGrid = *etk.NewGrid()
 	b.matchStatusGrid.AddChildAt(b.timerLabel, 0, 0, 1, 1)
 	b.matchStatusGrid.AddChildAt(b.clockLabel, 1, 0, 1, 1)
+	b.matchStatusGrid.AddChildAt(b.latencyLabel, 2, 0, 1, 1)
 
 	b.fontUpdated()
 }
@@ -1685,51 +2309,16 @@ func randomizeByteSlice(b [][]byte) {
 	}
 }
 
-func LoadLocale(forceLanguage *language.Tag) error {
-	entries, err := assetFS.ReadDir("locales")
-	if err != nil {
-		return err
-	}
-
-	var available = []language.Tag{
-		language.MustParse("en_US"),
-	}
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-		available = append(available, language.MustParse(entry.Name()))
-	}
+// forcedLocale is the locale specified when loading translations, if any.
+var forcedLocale string
 
-	var preferred = []language.Tag{}
+// LoadLocale loads the translations for the specified language, or for the
+// language of the system when forceLanguage is nil.
+func LoadLocale(forceLanguage *language.Tag) error {
 	if forceLanguage != nil {
-		preferred = append(preferred, *forceLanguage)
-	} else {
-		systemLocale := os.Getenv("LANG")
-		if systemLocale != "" {
-			tag, err := language.Parse(systemLocale)
-			if err == nil {
-				preferred = append(preferred, tag)
-			}
-		}
-	}
-
-	useLanguage, _, _ := language.NewMatcher(available).Match(preferred...)
-	useLanguageCode := useLanguage.String()
-	if useLanguageCode == "" || strings.HasPrefix(useLanguageCode, "en") {
-		return nil
+		forcedLocale = forceLanguage.String()
 	}
-
-	b, err := assetFS.ReadFile(fmt.Sprintf("locales/%s/%s.po", strings.ReplaceAll(useLanguageCode, "-", "_"), strings.ReplaceAll(useLanguageCode, "-", "_")))
-	if err != nil {
-		return nil
-	}
-
-	po := gotext.NewPo()
-	po.Parse(b)
-
-	gotext.GetStorage().AddTranslator("boxcars", po)
-	return nil
+	return locales.Load(forceLanguage)
 }
 
 type ClickableText struct {
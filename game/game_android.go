@@ -13,12 +13,12 @@ import (
 )
 
 const (
-	DefaultServerAddress = "wss://ws.bgammon.org"
 	OptimizeDraw         = false
 	OptimizeSetRect      = false
 	AutoEnableTouchInput = true
 	ShowServerSettings   = true
-	APPNAME              = "boxcars-android"
+	ShowMatchPicker      = false
+	ShowAutoSaveMatches  = true
 )
 
 func init() {
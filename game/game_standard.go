@@ -3,12 +3,12 @@
 package game
 
 const (
-	DefaultServerAddress = "tcp://bgammon.org:1337"
 	OptimizeDraw         = true
 	OptimizeSetRect      = true
 	AutoEnableTouchInput = false
 	ShowServerSettings   = false
-	APPNAME              = "boxcars"
+	ShowMatchPicker      = false
+	ShowAutoSaveMatches  = true
 )
 
 func DefaultLocale() string {
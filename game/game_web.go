@@ -7,12 +7,12 @@ import (
 )
 
 const (
-	DefaultServerAddress = "wss://ws.bgammon.org"
 	OptimizeDraw         = true
 	OptimizeSetRect      = true
 	AutoEnableTouchInput = false
 	ShowServerSettings   = false
-	APPNAME              = "boxcars"
+	ShowMatchPicker      = true
+	ShowAutoSaveMatches  = false
 )
 
 func DefaultLocale() string {
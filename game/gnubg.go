@@ -0,0 +1,82 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"code.rocket9labs.com/tslocum/boxcars/game/match"
+	"code.rocket9labs.com/tslocum/etk"
+	"github.com/leonelquinteros/gotext"
+)
+
+// updatePositionLabel updates the Position ID and Match ID shown in the menu.
+func (b *board) updatePositionLabel() {
+	go func() {
+		b.Lock()
+		state := b.displayState()
+		g := match.ServerGame(state)
+		positionID, matchID := match.PositionID(g), match.MatchID(g)
+		b.Unlock()
+
+		b.positionLabel.SetText(gotext.Get("Position ID: %s", positionID) + "\n" + gotext.Get("Match ID: %s", matchID))
+		scheduleFrame()
+	}()
+}
+
+func (b *board) copyPosition() error {
+	go func() {
+		b.Lock()
+		state := b.displayState()
+		g := match.ServerGame(state)
+		text := fmt.Sprintf("Position ID: %s Match ID: %s", match.PositionID(g), match.MatchID(g))
+		b.Unlock()
+
+		err := setClipboard(text)
+		if err != nil {
+			l("*** " + gotext.Get("Failed to copy to clipboard: %s", err))
+			return
+		}
+		l("*** " + gotext.Get("Copied to clipboard: %s", text))
+	}()
+	return nil
+}
+
+func (b *board) showEnterPosition() error {
+	b.menuGrid.SetVisible(false)
+	b.positionIDInput.Field.SetText("")
+	b.matchIDInput.Field.SetText("")
+	b.positionInfo.SetText(gotext.Get("Enter a GNU Backgammon Position ID and, optionally, a Match ID."))
+	b.enterPositionGrid.SetVisible(true)
+	etk.SetFocus(b.positionIDInput)
+	return nil
+}
+
+func (b *board) selectEnterPosition(text string) (handled bool) {
+	b.confirmEnterPosition()
+	return true
+}
+
+// confirmEnterPosition shows the position entered on the board. The live game
+// state is shown again by selecting Live in the move history.
+func (b *board) confirmEnterPosition() error {
+	positionID, matchID := match.ParsePositionText(b.positionIDInput.Text())
+	if id := strings.TrimSpace(b.matchIDInput.Text()); id != "" {
+		matchID = id
+	}
+	if positionID == "" {
+		positionID = b.positionIDInput.Text()
+	}
+	state, err := match.DecodePosition(positionID, matchID)
+	if err != nil {
+		b.positionInfo.SetText(err.Error())
+		return nil
+	}
+	b.hideMenu()
+
+	go func() {
+		b.Lock()
+		defer b.Unlock()
+		b.showHistoryEntry(&match.HistoryEntry{State: state})
+	}()
+	return nil
+}
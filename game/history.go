@@ -0,0 +1,326 @@
+package game
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
+	"code.rocket9labs.com/tslocum/boxcars/game/match"
+	"code.rocket9labs.com/tslocum/etk"
+	"github.com/leonelquinteros/gotext"
+)
+
+// subscribeHistory subscribes the board to the events recorded in the move
+// history. The board must already be subscribed, so that the game state is
+// updated before it is recorded.
+func (b *board) subscribeHistory(d *client.Dispatcher) {
+	d.Subscribe((*bgammon.EventBoard)(nil), b.recordBoard)
+	d.Subscribe((*bgammon.EventRolled)(nil), b.recordRolled)
+	d.Subscribe((*bgammon.EventMoved)(nil), b.recordMoved)
+	d.Subscribe((*bgammon.EventWin)(nil), b.recordWin)
+}
+
+func (b *board) recordBoard(e interface{}) {
+	b.Lock()
+	defer b.Unlock()
+
+	state := b.gameState
+	if state.Player1.Name == "" || state.Player2.Name == "" {
+		return
+	}
+	if !b.history.Matches(state) {
+		b.history = match.NewHistory(state)
+		b.viewEntry = nil
+	}
+	b.history.RecordBoard(state)
+	b.updateHistoryList()
+}
+
+func (b *board) recordRolled(e interface{}) {
+	ev := e.(*bgammon.EventRolled)
+	b.Lock()
+	defer b.Unlock()
+
+	h := b.history
+	if !h.Matches(b.gameState) {
+		return
+	}
+	h.RecordRolled(b.gameState, ev)
+	b.updateHistoryList()
+}
+
+func (b *board) recordMoved(e interface{}) {
+	ev := e.(*bgammon.EventMoved)
+	b.Lock()
+	defer b.Unlock()
+
+	h := b.history
+	if !h.Matches(b.gameState) {
+		return
+	}
+	h.RecordMoved(b.gameState, ev)
+	b.updateHistoryList()
+}
+
+func (b *board) recordWin(e interface{}) {
+	ev := e.(*bgammon.EventWin)
+	b.Lock()
+	defer b.Unlock()
+
+	h := b.history
+	if h == nil || !h.RecordWin(b.gameState, ev) {
+		return
+	}
+	if h.Finished() {
+		autoSaveMatch(h)
+	}
+	b.updateHistoryList()
+}
+
+// formatHistoryEntry returns a description of an action.
+func formatHistoryEntry(h *match.History, entry *match.HistoryEntry) string {
+	name := h.PlayerName(entry.Player)
+	switch entry.Action {
+	case match.HistoryMove:
+		moves := string(bgammon.FormatMoves(entry.Moves))
+		if moves == "" {
+			moves = gotext.Get("no moves")
+		}
+		return fmt.Sprintf("%d. %s: %d-%d %s", entry.Turn, name, entry.Roll1, entry.Roll2, moves)
+	case match.HistoryDouble:
+		return fmt.Sprintf("%d. %s", entry.Turn, gotext.Get("%s doubles to %d", name, entry.Value))
+	case match.HistoryTake:
+		return fmt.Sprintf("%d. %s", entry.Turn, gotext.Get("%s takes", name))
+	case match.HistoryDrop:
+		return fmt.Sprintf("%d. %s", entry.Turn, gotext.Get("%s drops", name))
+	case match.HistoryWin:
+		return fmt.Sprintf("%d. %s", entry.Turn, gotext.Get("%s wins %d point(s)", name, entry.Value))
+	default:
+		return ""
+	}
+}
+
+// historyList displays the move history. Clicking an entry shows the
+// position after the action. The lines and the number of visible lines are
+// guarded by the board lock.
+type historyList struct {
+	*etk.Text
+	b *board
+
+	lines   []*match.HistoryEntry // Entry of each visible line. Nil for game headers.
+	visible int                   // Number of lines which fit in the list.
+}
+
+func newHistoryList(b *board) *historyList {
+	l := &historyList{
+		Text: etk.NewText(""),
+		b:    b,
+	}
+	l.SetScrollBarVisible(false)
+	l.TextField.SetWordWrap(false)
+	return l
+}
+
+func (l *historyList) SetRect(r image.Rectangle) {
+	l.Text.SetRect(r)
+	lineHeight := l.TextField.LineHeight()
+	if lineHeight == 0 {
+		return
+	}
+	visible := (r.Dy() - l.TextField.Padding()*2) / lineHeight
+	go func() {
+		l.b.Lock()
+		defer l.b.Unlock()
+		if visible == l.visible {
+			return
+		}
+		l.visible = visible
+		l.b.updateHistoryList()
+	}()
+}
+
+func (l *historyList) HandleMouse(cursor image.Point, pressed bool, clicked bool) (handled bool, err error) {
+	if !clicked {
+		return true, nil
+	}
+	lineHeight := l.TextField.LineHeight()
+	if lineHeight == 0 {
+		return true, nil
+	}
+	line := (cursor.Y - l.Rect().Min.Y - l.TextField.Padding()) / lineHeight
+	go func() {
+		l.b.Lock()
+		defer l.b.Unlock()
+		if line >= 0 && line < len(l.lines) && l.lines[line] != nil {
+			l.b.showHistoryEntry(l.lines[line])
+		}
+	}()
+	return true, nil
+}
+
+// updateHistoryList updates the move history panel. The board must be
+// locked.
+func (b *board) updateHistoryList() {
+	l := b.historyList
+	if l == nil || !b.showHistory {
+		return
+	}
+	h := b.history
+	if h == nil || len(h.Games) == 0 {
+		l.lines = nil
+		l.SetText(gotext.Get("No moves have been played."))
+		scheduleFrame()
+		return
+	}
+
+	var lines []string
+	var entries []*match.HistoryEntry
+	selected := -1
+	for _, g := range h.Games {
+		lines = append(lines, gotext.Get("Game %d (%d-%d)", g.Number, g.Score1, g.Score2))
+		entries = append(entries, nil)
+		for _, entry := range g.Entries {
+			prefix := "  "
+			if entry == b.viewEntry {
+				prefix = "> "
+				selected = len(lines)
+			}
+			lines = append(lines, prefix+formatHistoryEntry(h, entry))
+			entries = append(entries, entry)
+		}
+	}
+
+	// Keep the selected entry, or the latest entry when following the live
+	// game, in view.
+	first := 0
+	if l.visible > 0 && len(lines) > l.visible {
+		first = len(lines) - l.visible
+		if selected != -1 && selected < first {
+			first = selected - l.visible/2
+			if first < 0 {
+				first = 0
+			}
+		}
+		lines, entries = lines[first:first+l.visible], entries[first:first+l.visible]
+	}
+	l.lines = entries
+	l.SetText(strings.Join(lines, "\n"))
+	scheduleFrame()
+}
+
+// showHistoryEntry shows the position after an action instead of the live
+// game state. When entry is nil, the live game state is shown. The board must
+// be locked.
+func (b *board) showHistoryEntry(entry *match.HistoryEntry) {
+	if entry != nil && entry.State == nil {
+		return
+	} else if entry == nil && game.viewingMatch {
+		// There is no live game when viewing a match file.
+		entries := b.history.Entries()
+		if len(entries) == 0 {
+			return
+		}
+		entry = entries[len(entries)-1]
+	}
+	b.viewEntry = entry
+	b.dragging = nil
+	b.processState()
+	b.updateHistoryList()
+	scheduleFrame()
+}
+
+// stepHistory shows the position before or after the position being shown.
+// The board must be locked.
+func (b *board) stepHistory(offset int) {
+	var entries []*match.HistoryEntry
+	for _, entry := range b.history.Entries() {
+		if entry.State != nil {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	index := len(entries)
+	for i, entry := range entries {
+		if entry == b.viewEntry {
+			index = i
+			break
+		}
+	}
+	index += offset
+	if index < 0 {
+		index = 0
+	} else if index >= len(entries) {
+		// Stepping past the last entry returns to the live game.
+		b.showHistoryEntry(nil)
+		return
+	}
+	b.showHistoryEntry(entries[index])
+}
+
+func (b *board) selectHistoryFirst() error {
+	go func() {
+		b.Lock()
+		defer b.Unlock()
+		b.stepHistory(-len(b.history.Entries()) - 1)
+	}()
+	return nil
+}
+
+func (b *board) selectHistoryPrevious() error {
+	go func() {
+		b.Lock()
+		defer b.Unlock()
+		b.stepHistory(-1)
+	}()
+	return nil
+}
+
+func (b *board) selectHistoryNext() error {
+	go func() {
+		b.Lock()
+		defer b.Unlock()
+		b.stepHistory(1)
+	}()
+	return nil
+}
+
+func (b *board) selectHistoryLive() error {
+	go func() {
+		b.Lock()
+		defer b.Unlock()
+		b.showHistoryEntry(nil)
+	}()
+	return nil
+}
+
+// toggleHistory shows or hides the move history panel in place of the game
+// log. The live game state is shown when the panel is hidden.
+func (b *board) toggleHistory() error {
+	b.menuGrid.SetVisible(false)
+	b.showHistory = !b.showHistory
+	b.recreateUIGrid()
+
+	go func() {
+		b.Lock()
+		defer b.Unlock()
+		if !b.showHistory && b.viewEntry != nil {
+			b.showHistoryEntry(nil)
+		}
+		b.updateHistoryList()
+	}()
+	return nil
+}
+
+// displayState returns the game state which is drawn on the board: the
+// position selected in the move history, or the live game state.
+func (b *board) displayState() *bgammon.GameState {
+	if b.viewEntry != nil {
+		return b.viewEntry.State
+	}
+	return b.gameState
+}
@@ -0,0 +1,38 @@
+package game
+
+import (
+	"image/color"
+	"time"
+
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
+)
+
+// qualityColor returns the color used to display a connection quality.
+func qualityColor(q client.ConnectionQuality) color.Color {
+	switch q {
+	case client.QualityGood:
+		return color.RGBA{0, 200, 0, 255}
+	case client.QualityFair:
+		return color.RGBA{230, 200, 0, 255}
+	case client.QualityPoor:
+		return color.RGBA{230, 40, 40, 255}
+	default:
+		return triangleA
+	}
+}
+
+// latencyText returns a short description of the round-trip time to the
+// server, or an empty string when it has not been measured.
+func latencyText(c *client.Client) string {
+	if c == nil || !c.Connected() {
+		return ""
+	}
+	average, jitter := c.Latency()
+	if average == 0 {
+		return ""
+	}
+	if jitter >= time.Millisecond {
+		return client.FormatLatency(average) + "±" + client.FormatLatency(jitter)
+	}
+	return client.FormatLatency(average)
+}
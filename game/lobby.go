@@ -1,16 +1,17 @@
 package game
 
 import (
-	"fmt"
 	"image"
 	"image/color"
 	"math"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"code.rocket9labs.com/tslocum/bgammon"
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
 	"code.rocket9labs.com/tslocum/etk"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
@@ -28,6 +29,7 @@ const (
 	lobbyButtonRefresh = iota
 	lobbyButtonCreate
 	lobbyButtonJoin
+	lobbyButtonSwitch
 )
 
 type lobbyButton struct {
@@ -39,6 +41,7 @@ type lobbyButton struct {
 var mainButtons []*lobbyButton
 var createButtons []*lobbyButton
 var cancelJoinButtons []*lobbyButton
+var switchButton *lobbyButton
 
 type lobby struct {
 	x, y int
@@ -64,7 +67,7 @@ type lobby struct {
 	buffer      *ebiten.Image
 	bufferDirty bool
 
-	c *Client
+	c *client.Client
 
 	refresh bool
 
@@ -104,6 +107,8 @@ func NewLobby() *lobby {
 		{gotext.Get("Join")},
 	}
 
+	switchButton = &lobbyButton{gotext.Get("Switch")}
+
 	l := &lobby{
 		refresh:     true,
 		fontFace:    mediumFont,
@@ -166,29 +171,61 @@ func (l *lobby) setGameList(games []bgammon.GameListing) {
 	l.bufferDirty = true
 }
 
+// subscribe subscribes the lobby to the events it displays.
+func (l *lobby) subscribe(d *client.Dispatcher) {
+	d.Subscribe((*bgammon.EventList)(nil), l.handleList)
+}
+
+func (l *lobby) handleList(e interface{}) {
+	ev := e.(*bgammon.EventList)
+	l.setGameList(ev.Games)
+	if !viewBoard {
+		scheduleFrame()
+	}
+}
+
 func (l *lobby) getButtons() []*lobbyButton {
 	if l.showCreateGame {
 		return createButtons
 	} else if l.showJoinGame {
 		return cancelJoinButtons
+	} else if game != nil && atomic.LoadInt32(&game.sessionCount) > 1 {
+		return append(mainButtons[:len(mainButtons):len(mainButtons)], switchButton)
 	}
 	return mainButtons
 }
 
 func (l *lobby) confirmCreateGame() {
-	typeAndPassword := "public"
-	if len(strings.TrimSpace(game.lobby.createGamePassword.Text())) > 0 {
-		typeAndPassword = fmt.Sprintf("private %s", strings.ReplaceAll(game.lobby.createGamePassword.Text(), " ", "_"))
-	}
+	password := game.lobby.createGamePassword.Text()
 	points, err := strconv.Atoi(game.lobby.createGamePoints.Text())
 	if err != nil {
 		points = 1
 	}
-	l.c.Out <- []byte(fmt.Sprintf("c %s %d %s", typeAndPassword, points, game.lobby.createGameName.Text()))
+	err = l.c.CreateMatch(client.MatchOptions{
+		Name:     game.lobby.createGameName.Text(),
+		Points:   points,
+		Password: password,
+	})
+	if err != nil {
+		showMatchPasswordError()
+		return
+	}
+	game.matchPassword = password
 }
 
 func (l *lobby) confirmJoinGame() {
-	l.c.Out <- []byte(fmt.Sprintf("j %d %s", l.joinGameID, l.joinGamePassword.Text()))
+	password := l.joinGamePassword.Text()
+	err := l.c.Join(l.joinGameID, password)
+	if err != nil {
+		showMatchPasswordError()
+		return
+	}
+	game.matchPassword = password
+}
+
+// showMatchPasswordError reports a match password which may not be sent.
+func showMatchPasswordError() {
+	l("*** " + gotext.Get("Match passwords may not contain spaces."))
 }
 
 func (l *lobby) selectButton(buttonIndex int) func() error {
@@ -222,19 +259,30 @@ func (l *lobby) selectButton(buttonIndex int) func() error {
 			return nil
 		}
 
+		if buttonIndex == lobbyButtonSwitch {
+			game.showSessions()
+			return nil
+		} else if !l.c.Connected() {
+			if buttonIndex == lobbyButtonRefresh {
+				l.c.Retry()
+			}
+			return nil
+		}
+
 		switch buttonIndex {
 		case lobbyButtonRefresh:
 			l.refresh = true
-			l.c.Out <- []byte("ls")
+			l.c.List()
 		case lobbyButtonCreate:
-			if l.c.Username == "" {
+			name := l.c.PlayerName()
+			if name == "" {
 				return nil
 			}
 
 			l.showCreateGame = true
 			game.setRoot(createGameFrame)
 			etk.SetFocus(l.createGameName)
-			namePlural := l.c.Username
+			namePlural := name
 			lastLetter := namePlural[len(namePlural)-1]
 			if lastLetter == 's' || lastLetter == 'S' {
 				namePlural += "'"
@@ -252,7 +300,7 @@ func (l *lobby) selectButton(buttonIndex int) func() error {
 		if l.selected < 0 || l.selected >= len(l.games) {
 			return
 		}
-		l.c.Out <- []byte(fmt.Sprintf("watch %d", l.games[l.selected].ID))
+		l.c.Watch(l.games[l.selected].ID)
 		setViewBoard(true)*/
 		case lobbyButtonJoin:
 			if l.selected < 0 || l.selected >= len(l.games) {
@@ -269,7 +317,8 @@ func (l *lobby) selectButton(buttonIndex int) func() error {
 				l.bufferDirty = true
 				l.rebuildButtonsGrid()
 			} else {
-				l.c.Out <- []byte(fmt.Sprintf("j %d", l.games[l.selected].ID))
+				game.matchPassword = ""
+				l.c.Join(l.games[l.selected].ID, "")
 				setViewBoard(true)
 				scheduleFrame()
 			}
@@ -340,6 +389,11 @@ func (l *lobby) drawBuffer() {
 
 	titleOffset := 2.0
 
+	if l.c != nil && !l.c.Connected() {
+		drawEntry(l.padding, l.padding-titleOffset, connectionStatusText(l.c), "", "", false, true)
+		return
+	}
+
 	if !l.loaded {
 		drawEntry(l.padding, l.padding-titleOffset, "Loading...", "Please", "wait...", false, true)
 		return
@@ -353,6 +407,12 @@ func (l *lobby) drawBuffer() {
 
 	cx, cy := 0.0, 0.0 // Cursor
 	drawEntry(cx+l.padding, cy+l.padding-titleOffset, gotext.Get("Status"), gotext.Get("Points"), gotext.Get("Name"), false, true)
+	if latency := latencyText(l.c); latency != "" {
+		fontMutex.Lock()
+		bounds := text.BoundString(l.fontFace, latency)
+		text.Draw(l.buffer, latency, l.fontFace, l.w-int(l.padding)-bounds.Dx()-4, int(cy+l.padding-titleOffset)+l.lineOffset, qualityColor(l.c.Quality()))
+		fontMutex.Unlock()
+	}
 	cy += l.entryH
 
 	if len(l.games) == 0 {
@@ -444,6 +504,10 @@ func (l *lobby) click(x, y int) {
 		if l.selected == newSelection && l.selected >= 0 && l.selected < len(l.games) {
 			if time.Since(l.lastClick) <= doubleClickDuration {
 				entry := l.games[l.selected]
+				if !l.c.Connected() {
+					l.lastClick = time.Time{}
+					return
+				}
 				if entry.Password {
 					l.showJoinGame = true
 					game.setRoot(joinGameFrame)
@@ -454,7 +518,8 @@ func (l *lobby) click(x, y int) {
 					l.bufferDirty = true
 					l.rebuildButtonsGrid()
 				} else {
-					l.c.Out <- []byte(fmt.Sprintf("j %d", entry.ID))
+					game.matchPassword = ""
+					l.c.Join(entry.ID, "")
 				}
 				l.lastClick = time.Time{}
 				return
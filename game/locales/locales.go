@@ -0,0 +1,64 @@
+// Package locales provides the translations of Boxcars.
+package locales
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"golang.org/x/text/language"
+)
+
+//go:embed */*.po
+var localeFS embed.FS
+
+// Load loads the translations for the specified language, or for the language
+// of the system when forceLanguage is nil.
+func Load(forceLanguage *language.Tag) error {
+	entries, err := localeFS.ReadDir(".")
+	if err != nil {
+		return err
+	}
+
+	var available = []language.Tag{
+		language.MustParse("en_US"),
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		available = append(available, language.MustParse(entry.Name()))
+	}
+
+	var preferred = []language.Tag{}
+	if forceLanguage != nil {
+		preferred = append(preferred, *forceLanguage)
+	} else {
+		systemLocale := os.Getenv("LANG")
+		if systemLocale != "" {
+			tag, err := language.Parse(systemLocale)
+			if err == nil {
+				preferred = append(preferred, tag)
+			}
+		}
+	}
+
+	useLanguage, _, _ := language.NewMatcher(available).Match(preferred...)
+	useLanguageCode := useLanguage.String()
+	if useLanguageCode == "" || strings.HasPrefix(useLanguageCode, "en") {
+		return nil
+	}
+
+	b, err := localeFS.ReadFile(fmt.Sprintf("%s/%s.po", strings.ReplaceAll(useLanguageCode, "-", "_"), strings.ReplaceAll(useLanguageCode, "-", "_")))
+	if err != nil {
+		return nil
+	}
+
+	po := gotext.NewPo()
+	po.Parse(b)
+
+	gotext.GetStorage().AddTranslator("boxcars", po)
+	return nil
+}
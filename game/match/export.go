@@ -0,0 +1,251 @@
+package match
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
+)
+
+// Match file formats.
+const (
+	FormatMat = "mat" // Jellyfish text match, read by GNU Backgammon and most analysis software.
+	FormatSGF = "sgf" // Smart Game Format, as written by GNU Backgammon.
+)
+
+// Score returns the points of each player, including the points won in the
+// last game when it is finished.
+func (h *History) Score() (int, int) {
+	g := h.game()
+	score1, score2 := g.Score1, g.Score2
+	for _, entry := range g.Entries {
+		if entry.Action != HistoryWin {
+			continue
+		} else if entry.Player == 1 {
+			score1 += entry.Value
+		} else {
+			score2 += entry.Value
+		}
+	}
+	return score1, score2
+}
+
+// Finished returns whether a player has won the match.
+func (h *History) Finished() bool {
+	if !h.game().Finished {
+		return false
+	}
+	score1, score2 := h.Score()
+	return score1 >= h.Points || score2 >= h.Points
+}
+
+// Write writes the match in the specified format.
+func (h *History) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatMat:
+		return h.WriteMat(w)
+	case FormatSGF:
+		return h.WriteSGF(w)
+	default:
+		return fmt.Errorf("unknown match format: %s", format)
+	}
+}
+
+// FileName returns the name of the file the match is saved to in the
+// specified format.
+func (h *History) FileName(format string) string {
+	started := h.Started
+	if started.IsZero() {
+		started = time.Now()
+	}
+	name := func(player string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '/' || r == '\\' || r == ':' || r <= ' ' {
+				return '_'
+			}
+			return r
+		}, player)
+	}
+	return fmt.Sprintf("%s_%s_%s.%s", started.Format("2006-01-02_15-04-05"), name(h.Player1), name(h.Player2), format)
+}
+
+// matSpace returns the number of a space from the perspective of a player, as
+// written in match files: spaces are numbered from 24 to 1 towards the
+// player's home, the bar is 25 and checkers borne off are moved to 0.
+func matSpace(space int, player int) int {
+	switch space {
+	case bgammon.SpaceBarPlayer, bgammon.SpaceBarOpponent:
+		return 25
+	case bgammon.SpaceHomePlayer, bgammon.SpaceHomeOpponent:
+		return 0
+	}
+	if player == 2 {
+		return 25 - space
+	}
+	return space
+}
+
+// matAction returns an action as written in Jellyfish match files.
+func matAction(entry *HistoryEntry) string {
+	switch entry.Action {
+	case HistoryMove:
+		moves := make([]string, len(entry.Moves))
+		for i, move := range entry.Moves {
+			moves[i] = fmt.Sprintf("%d/%d", matSpace(move[0], entry.Player), matSpace(move[1], entry.Player))
+		}
+		return strings.TrimSpace(fmt.Sprintf("%d%d: %s", entry.Roll1, entry.Roll2, strings.Join(moves, " ")))
+	case HistoryDouble:
+		return fmt.Sprintf(" Doubles => %d", entry.Value)
+	case HistoryTake:
+		return " Takes"
+	case HistoryDrop:
+		return " Drops"
+	case HistoryWin:
+		if entry.Value == 1 {
+			return "Wins 1 point"
+		}
+		return fmt.Sprintf("Wins %d points", entry.Value)
+	default:
+		return ""
+	}
+}
+
+// Layout of the turns written in Jellyfish match files. Each turn is numbered
+// and followed by the action of player 1 and the action of player 2.
+const (
+	matNumberWidth = 5  // Width of the turn number, such as "  1) ".
+	matColumnWidth = 28 // Width of the action of player 1.
+
+	// matRightColumn is the position of the action of player 2.
+	matRightColumn = matNumberWidth + matColumnWidth + 1
+)
+
+// WriteMat writes the match as a Jellyfish text match (.mat). The actions of
+// player 1 are written in the left column and the actions of player 2 in the
+// right column. The winner of each game is written below the last turn in
+// the column of the winner.
+func (h *History) WriteMat(w io.Writer) error {
+	started := h.Started
+	if started.IsZero() {
+		started = time.Now()
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "; [Player 1 \"%s\"]\n", h.Player1)
+	fmt.Fprintf(buf, "; [Player 2 \"%s\"]\n", h.Player2)
+	fmt.Fprintf(buf, "; [EventDate \"%s\"]\n", started.Format("2006.01.02"))
+	fmt.Fprintf(buf, "; [EventTime \"%s\"]\n", started.Format("15.04"))
+	fmt.Fprintf(buf, "; [Variation \"Backgammon\"]\n")
+	fmt.Fprintf(buf, "; [Transcriber \"%s\"]\n", client.APPNAME)
+	fmt.Fprintf(buf, "\n %d point match\n", h.Points)
+
+	for _, g := range h.Games {
+		fmt.Fprintf(buf, "\n Game %d\n", g.Number)
+		fmt.Fprintf(buf, " %-33s%s : %d\n", fmt.Sprintf("%s : %d", h.Player1, g.Score1), h.Player2, g.Score2)
+
+		var rows [][2]string
+		var win *HistoryEntry
+		for _, entry := range g.Entries {
+			if entry.Action == HistoryWin {
+				win = entry
+				continue
+			}
+			action := matAction(entry)
+			if entry.Player == 2 && len(rows) > 0 && rows[len(rows)-1][1] == "" {
+				rows[len(rows)-1][1] = action
+			} else if entry.Player == 2 {
+				rows = append(rows, [2]string{"", action})
+			} else {
+				rows = append(rows, [2]string{action, ""})
+			}
+		}
+		for i, row := range rows {
+			fmt.Fprintf(buf, "%s\n", strings.TrimRight(fmt.Sprintf("%3d) %-*s %s", i+1, matColumnWidth, row[0], row[1]), " "))
+		}
+		if win != nil {
+			column := matNumberWidth
+			if win.Player == 2 {
+				column = matRightColumn
+			}
+			fmt.Fprintf(buf, "%s%s\n", strings.Repeat(" ", column), matAction(win))
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// sgfText escapes a value of an SGF property.
+func sgfText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `]`, `\]`).Replace(s)
+}
+
+// sgfSpace returns a space from the perspective of a player as written in SGF
+// files: spaces 1 to 24 are written as a to x, the bar as y and checkers borne
+// off as z.
+func sgfSpace(space int, player int) byte {
+	switch n := matSpace(space, player); n {
+	case 25:
+		return 'y'
+	case 0:
+		return 'z'
+	default:
+		return byte('a' + n - 1)
+	}
+}
+
+// WriteSGF writes the match in the Smart Game Format (.sgf), as a collection
+// of one game tree per game. Player 1 is written as black and player 2 as
+// white.
+func (h *History) WriteSGF(w io.Writer) error {
+	started := h.Started
+	if started.IsZero() {
+		started = time.Now()
+	}
+	color := func(player int) string {
+		if player == 2 {
+			return "W"
+		}
+		return "B"
+	}
+
+	buf := &bytes.Buffer{}
+	for _, g := range h.Games {
+		fmt.Fprintf(buf, "(;FF[4]GM[6]CA[UTF-8]AP[%s]MI[length:%d][game:%d][bs:%d][ws:%d]PB[%s]PW[%s]DT[%s]", client.APPNAME, h.Points, g.Number-1, g.Score1, g.Score2, sgfText(h.Player1), sgfText(h.Player2), started.Format("2006-01-02"))
+		for _, entry := range g.Entries {
+			if entry.Action == HistoryWin {
+				fmt.Fprintf(buf, "RE[%s+%d]", color(entry.Player), entry.Value)
+			}
+		}
+		buf.WriteByte('\n')
+
+		for _, entry := range g.Entries {
+			var value string
+			switch entry.Action {
+			case HistoryMove:
+				move := []byte(fmt.Sprintf("%d%d", entry.Roll1, entry.Roll2))
+				for _, m := range entry.Moves {
+					move = append(move, sgfSpace(m[0], entry.Player), sgfSpace(m[1], entry.Player))
+				}
+				value = string(move)
+			case HistoryDouble:
+				value = "double"
+			case HistoryTake:
+				value = "take"
+			case HistoryDrop:
+				value = "drop"
+			default:
+				continue
+			}
+			fmt.Fprintf(buf, ";%s[%s]\n", color(entry.Player), value)
+		}
+		buf.WriteString(")\n")
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
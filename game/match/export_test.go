@@ -0,0 +1,85 @@
+package match
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
+)
+
+// testHistory returns a match won by Alice when Bob drops a double.
+func testHistory() *History {
+	return &History{
+		Player1: "Alice",
+		Player2: "Bob",
+		Points:  1,
+		Started: time.Date(2026, 10, 17, 12, 30, 0, 0, time.Local),
+		Games: []*HistoryGame{{
+			Number: 1,
+			Entries: []*HistoryEntry{
+				{Action: HistoryMove, Player: 1, Turn: 1, Roll1: 3, Roll2: 1, Moves: [][]int{{8, 5}, {6, 5}}},
+				{Action: HistoryMove, Player: 2, Turn: 2, Roll1: 6, Roll2: 4, Moves: [][]int{{1, 11}}},
+				{Action: HistoryDouble, Player: 1, Turn: 3, Value: 2},
+				{Action: HistoryDrop, Player: 2, Turn: 3},
+				{Action: HistoryWin, Player: 1, Turn: 3, Value: 1},
+			},
+			Finished: true,
+		}},
+	}
+}
+
+func TestExportMat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := testHistory().Write(buf, FormatMat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Moves of player 2 are numbered from their own perspective.
+	expected := `; [Player 1 "Alice"]
+; [Player 2 "Bob"]
+; [EventDate "2026.10.17"]
+; [EventTime "12.30"]
+; [Variation "Backgammon"]
+; [Transcriber "` + client.APPNAME + `"]
+
+ 1 point match
+
+ Game 1
+ Alice : 0                        Bob : 0
+  1) 31: 8/5 6/5                  64: 24/14
+  2)  Doubles => 2                 Drops
+     Wins 1 point
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected match file:\n%s\nexpected:\n%s", buf, expected)
+	}
+}
+
+func TestExportSGF(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := testHistory().Write(buf, FormatSGF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `(;FF[4]GM[6]CA[UTF-8]AP[` + client.APPNAME + `]MI[length:1][game:0][bs:0][ws:0]PB[Alice]PW[Bob]DT[2026-10-17]RE[B+1]
+;B[31hefe]
+;W[64xn]
+;B[double]
+;W[drop]
+)
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected match file:\n%s\nexpected:\n%s", buf, expected)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	h := testHistory()
+	h.Player2 = "Bob/Carol"
+	if name := h.FileName(FormatSGF); name != "2026-10-17_12-30-00_Alice_Bob_Carol.sgf" {
+		t.Fatalf("unexpected file name: %s", name)
+	}
+}
@@ -0,0 +1,250 @@
+package match
+
+import (
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"strings"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+	"github.com/leonelquinteros/gotext"
+)
+
+// GNU Backgammon identifies positions using a Position ID, which encodes the
+// checkers of each player, and a Match ID, which encodes the cube, dice and
+// score. Player 1 is GNU Backgammon player 0 and player 2 is player 1.
+
+const (
+	positionIDLength = 14 // Base64 encoded 80 bit key.
+	matchIDLength    = 12 // Base64 encoded 66 bit key.
+)
+
+var positionTextPattern = regexp.MustCompile(`[A-Za-z0-9+/]+`)
+
+// bitWriter writes values least significant bit first.
+type bitWriter struct {
+	buf []byte
+	bit int
+}
+
+func (w *bitWriter) write(value int, bits int) {
+	for i := 0; i < bits; i++ {
+		if w.bit/8 >= len(w.buf) {
+			return
+		}
+		if value&(1<<i) != 0 {
+			w.buf[w.bit/8] |= 1 << (w.bit % 8)
+		}
+		w.bit++
+	}
+}
+
+// bitReader reads values written by a bitWriter.
+type bitReader struct {
+	buf []byte
+	bit int
+}
+
+func (r *bitReader) read(bits int) int {
+	var value int
+	for i := 0; i < bits; i++ {
+		if r.bit/8 >= len(r.buf) {
+			return value
+		}
+		if r.buf[r.bit/8]&(1<<(r.bit%8)) != 0 {
+			value |= 1 << i
+		}
+		r.bit++
+	}
+	return value
+}
+
+// playerCheckers returns the number of checkers a player has on a space.
+func playerCheckers(board []int, space int, player int) int {
+	v := board[space]
+	if player == 2 {
+		v = -v
+	}
+	if v < 0 {
+		return 0
+	}
+	return v
+}
+
+// playerOnRoll returns the player whose turn it is, or player 1 before the
+// opening roll.
+func playerOnRoll(g *bgammon.Game) int {
+	if g.Turn == 0 {
+		return 1
+	}
+	return g.Turn
+}
+
+// ServerGame returns the game of a state with the board as stored by the
+// server. The second player is sent the board from their own side, which is
+// flipped back so that the IDs of a position are the same for both players.
+func ServerGame(state *bgammon.GameState) *bgammon.Game {
+	if state.PlayerNumber != 2 {
+		return state.Game
+	}
+	g := *state.Game
+	g.Board = make([]int, len(state.Board))
+	for space := range g.Board {
+		g.Board[space] = state.Board[serverSpace(space, state.PlayerNumber)]
+	}
+	g.Moves = nil
+	return &g
+}
+
+// PositionID returns the GNU Backgammon Position ID of a game.
+func PositionID(g *bgammon.Game) string {
+	roller := playerOnRoll(g)
+	w := &bitWriter{buf: make([]byte, 10)}
+	for _, player := range []int{opponent(roller), roller} {
+		for point := 1; point <= 25; point++ {
+			for n := playerCheckers(g.Board, fromMatSpace(point, player), player); n > 0; n-- {
+				w.write(1, 1)
+			}
+			w.write(0, 1)
+		}
+	}
+	return base64.RawStdEncoding.EncodeToString(w.buf)
+}
+
+// MatchID returns the GNU Backgammon Match ID of a game.
+func MatchID(g *bgammon.Game) string {
+	roller := playerOnRoll(g)
+
+	cube := 0
+	for v := g.DoubleValue; v > 1; v /= 2 {
+		cube++
+	}
+	owner := 3 // Centered.
+	if g.DoublePlayer != 0 && (!g.DoubleOffered || g.DoubleValue > 1) {
+		owner = g.DoublePlayer - 1
+	}
+	state := 1 // Playing.
+	if g.Winner != 0 {
+		state = 2 // Game over.
+	}
+	decision := roller
+	if g.DoubleOffered {
+		decision = opponent(roller)
+	}
+	var roll1, roll2 int
+	if g.Turn != 0 {
+		roll1, roll2 = g.Roll1, g.Roll2
+	}
+	var doubleOffered int
+	if g.DoubleOffered {
+		doubleOffered = 1
+	}
+
+	w := &bitWriter{buf: make([]byte, 9)}
+	w.write(cube, 4)
+	w.write(owner, 2)
+	w.write(roller-1, 1)
+	w.write(0, 1) // Crawford game.
+	w.write(state, 3)
+	w.write(decision-1, 1)
+	w.write(doubleOffered, 1)
+	w.write(0, 2) // Resignation offered.
+	w.write(roll1, 3)
+	w.write(roll2, 3)
+	w.write(g.Points, 15)
+	w.write(g.Player1.Points, 15)
+	w.write(g.Player2.Points, 15)
+	return base64.RawStdEncoding.EncodeToString(w.buf)
+}
+
+// decodeID decodes a Position ID or Match ID of the specified length.
+func decodeID(id string, length int) ([]byte, error) {
+	id = strings.TrimRight(strings.TrimSpace(id), "=")
+	if len(id) != length {
+		return nil, errors.New("invalid length")
+	}
+	return base64.RawStdEncoding.DecodeString(id)
+}
+
+// DecodePosition returns the game described by a GNU Backgammon Position ID
+// and Match ID. When no Match ID is specified, player 1 is on roll in a game
+// which is not part of a match.
+func DecodePosition(positionID string, matchID string) (*bgammon.GameState, error) {
+	g := bgammon.NewGame()
+	g.Board = make([]int, bgammon.BoardSpaces)
+	g.Player1 = bgammon.Player{Number: 1, Name: gotext.Get("Player %d", 1)}
+	g.Player2 = bgammon.Player{Number: 2, Name: gotext.Get("Player %d", 2)}
+	g.Points = 1
+	g.DoubleValue = 1
+	g.Turn = 1
+
+	if strings.TrimSpace(matchID) != "" {
+		buf, err := decodeID(matchID, matchIDLength)
+		if err != nil {
+			return nil, errors.New(gotext.Get("Invalid Match ID."))
+		}
+		r := &bitReader{buf: buf}
+		g.DoubleValue = 1 << r.read(4)
+		if owner := r.read(2); owner != 3 {
+			g.DoublePlayer = owner + 1
+		}
+		g.Turn = r.read(1) + 1
+		r.read(1) // Crawford game.
+		r.read(3) // Game state.
+		r.read(1) // Player making a decision.
+		g.DoubleOffered = r.read(1) == 1
+		r.read(2) // Resignation offered.
+		g.Roll1, g.Roll2 = r.read(3), r.read(3)
+		g.Points = r.read(15)
+		g.Player1.Points = r.read(15)
+		g.Player2.Points = r.read(15)
+		if g.Roll1 > 6 || g.Roll2 > 6 {
+			return nil, errors.New(gotext.Get("Invalid Match ID."))
+		}
+	}
+
+	buf, err := decodeID(positionID, positionIDLength)
+	if err != nil {
+		return nil, errors.New(gotext.Get("Invalid Position ID."))
+	}
+	r := &bitReader{buf: buf}
+	for _, player := range []int{opponent(g.Turn), g.Turn} {
+		sign := 1
+		if player == 2 {
+			sign = -1
+		}
+		total := 0
+		for point := 1; point <= 25; point++ {
+			n := 0
+			for r.read(1) == 1 {
+				n++
+			}
+			g.Board[fromMatSpace(point, player)] += n * sign
+			total += n
+		}
+		if total > 15 {
+			return nil, errors.New(gotext.Get("Invalid Position ID."))
+		}
+		g.Board[fromMatSpace(0, player)] = (15 - total) * sign
+	}
+
+	return &bgammon.GameState{
+		Game:         g,
+		PlayerNumber: 1,
+		Spectating:   true,
+	}, nil
+}
+
+// ParsePositionText returns the Position ID and Match ID found in text, such
+// as text copied from GNU Backgammon.
+func ParsePositionText(text string) (positionID string, matchID string) {
+	for _, token := range positionTextPattern.FindAllString(text, -1) {
+		switch len(token) {
+		case positionIDLength:
+			positionID = token
+		case matchIDLength:
+			matchID = token
+		}
+	}
+	return positionID, matchID
+}
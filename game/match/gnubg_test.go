@@ -0,0 +1,142 @@
+package match
+
+import (
+	"reflect"
+	"testing"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+)
+
+// startPositionID is the Position ID of the starting position.
+const startPositionID = "4HPwATDgc/ABMA"
+
+func TestIDs(t *testing.T) {
+	newGame := func(turn int) *bgammon.Game {
+		g := bgammon.NewGame()
+		g.Turn = turn
+		g.DoubleValue = 1
+		return g
+	}
+
+	testCases := []struct {
+		name       string
+		game       *bgammon.Game
+		positionID string
+		matchID    string
+	}{
+		{
+			name:       "money game with player 2 on roll",
+			game:       newGame(2),
+			positionID: startPositionID,
+			matchID:    "cAkAAAAAAAAA",
+		},
+		{
+			// Example from the GNU Backgammon manual: player 1 owns the cube
+			// at 2 and player 2 rolled 5-2, with the score 2-4 in a 9 point
+			// match.
+			name: "9 point match",
+			game: func() *bgammon.Game {
+				g := newGame(2)
+				g.Roll1, g.Roll2 = 5, 2
+				g.DoubleValue = 2
+				g.DoublePlayer = 1
+				g.Points = 9
+				g.Player1.Points = 2
+				g.Player2.Points = 4
+				return g
+			}(),
+			positionID: startPositionID,
+			matchID:    "QYkqASAAIAAA",
+		},
+	}
+	for _, tc := range testCases {
+		if id := PositionID(tc.game); id != tc.positionID {
+			t.Errorf("%s: expected Position ID %s, got %s", tc.name, tc.positionID, id)
+		}
+		if id := MatchID(tc.game); id != tc.matchID {
+			t.Errorf("%s: expected Match ID %s, got %s", tc.name, tc.matchID, id)
+		}
+
+		state, err := DecodePosition(tc.positionID, tc.matchID)
+		if err != nil {
+			t.Errorf("%s: %s", tc.name, err)
+			continue
+		}
+		g := state.Game
+		if !reflect.DeepEqual(g.Board, tc.game.Board) {
+			t.Errorf("%s: expected board %v, got %v", tc.name, tc.game.Board, g.Board)
+		} else if g.Turn != tc.game.Turn || g.Roll1 != tc.game.Roll1 || g.Roll2 != tc.game.Roll2 || g.DoubleValue != tc.game.DoubleValue || g.DoublePlayer != tc.game.DoublePlayer || g.Points != tc.game.Points || g.Player1.Points != tc.game.Player1.Points || g.Player2.Points != tc.game.Player2.Points {
+			t.Errorf("%s: expected %+v, got %+v", tc.name, tc.game, g)
+		}
+	}
+}
+
+func TestServerGame(t *testing.T) {
+	g := bgammon.NewGame()
+	g.Turn = 2
+	g.DoubleValue = 1
+	g.Board[24], g.Board[18] = 1, 1
+
+	// The second player is sent the board from their own side.
+	local := *g
+	local.Board = make([]int, bgammon.BoardSpaces)
+	for space := range local.Board {
+		local.Board[space] = g.Board[serverSpace(space, 2)]
+	}
+	state := &bgammon.GameState{Game: &local, PlayerNumber: 2}
+	if !reflect.DeepEqual(ServerGame(state).Board, g.Board) {
+		t.Fatalf("expected board %v, got %v", g.Board, ServerGame(state).Board)
+	} else if PositionID(ServerGame(state)) != PositionID(g) {
+		t.Fatal("expected the same Position ID for both players")
+	}
+}
+
+// TestDecodePositionRoundTrip encodes every position of randomly played
+// matches and decodes it again.
+func TestDecodePositionRoundTrip(t *testing.T) {
+	for seed := int64(1); seed <= 3; seed++ {
+		for _, entry := range playRandomMatch(t, seed).Entries() {
+			g := entry.State.Game
+			if g.Winner != 0 {
+				continue
+			}
+			positionID, matchID := PositionID(g), MatchID(g)
+			state, err := DecodePosition(positionID, matchID)
+			if err != nil {
+				t.Fatalf("%s %s: %s", positionID, matchID, err)
+			} else if !reflect.DeepEqual(state.Board, g.Board) {
+				t.Fatalf("%s %s: expected board %v, got %v", positionID, matchID, g.Board, state.Board)
+			} else if id := PositionID(state.Game); id != positionID {
+				t.Fatalf("expected Position ID %s after decoding, got %s", positionID, id)
+			} else if id := MatchID(state.Game); id != matchID {
+				t.Fatalf("expected Match ID %s after decoding, got %s", matchID, id)
+			}
+		}
+	}
+}
+
+func TestDecodePositionInvalid(t *testing.T) {
+	for _, tc := range [][2]string{
+		{"", ""},
+		{"4HPwATDgc/AB", ""},
+		{"////////////////", ""},
+		{"//////////////", ""},
+		{startPositionID, "cAkAAAAAAAA"},
+		{startPositionID, "cAn/AAAAAAAA"},
+	} {
+		if _, err := DecodePosition(tc[0], tc[1]); err == nil {
+			t.Errorf("%q %q: expected error", tc[0], tc[1])
+		}
+	}
+}
+
+func TestParsePositionText(t *testing.T) {
+	positionID, matchID := ParsePositionText("Position ID: " + startPositionID + " Match ID: cAkAAAAAAAAA")
+	if positionID != startPositionID || matchID != "cAkAAAAAAAAA" {
+		t.Fatalf("unexpected IDs: %s %s", positionID, matchID)
+	}
+	positionID, matchID = ParsePositionText("GNU Backgammon  Position ID: " + startPositionID + "\n")
+	if positionID != startPositionID || matchID != "" {
+		t.Fatalf("unexpected IDs: %s %s", positionID, matchID)
+	}
+}
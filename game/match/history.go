@@ -0,0 +1,280 @@
+// Package match records the history of backgammon matches, reads and writes
+// match files and encodes positions as GNU Backgammon Position and Match IDs.
+package match
+
+import (
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+)
+
+// HistoryAction is the type of a HistoryEntry.
+type HistoryAction int
+
+const (
+	HistoryMove   HistoryAction = iota // Dice rolled and checkers moved.
+	HistoryDouble                      // Double offered.
+	HistoryTake                        // Double accepted.
+	HistoryDrop                        // Double declined.
+	HistoryWin                         // Game won.
+)
+
+// HistoryEntry is an action taken during a match.
+type HistoryEntry struct {
+	Action HistoryAction
+	Player int // Number of the player who took the action.
+	Turn   int // Number of the turn within the game, starting at 1.
+
+	Roll1, Roll2 int
+	Moves        [][]int // Spaces are numbered as stored by the server.
+
+	// Value is the value of the cube after a double, or the number of points
+	// won.
+	Value int
+
+	// State is the position after the action.
+	State *bgammon.GameState
+}
+
+// HistoryGame is a game played during a match.
+type HistoryGame struct {
+	Number         int // Number of the game within the match, starting at 1.
+	Score1, Score2 int // Points of each player at the start of the game.
+	Entries        []*HistoryEntry
+	Finished       bool
+}
+
+// History is the record of a match which was played or watched.
+type History struct {
+	Player1, Player2 string
+	Points           int
+	Started          time.Time
+	Games            []*HistoryGame
+
+	doubleOffered bool
+	doubleValue   int
+	doublePlayer  int
+}
+
+// NewHistory returns the history of the match in the specified state.
+func NewHistory(state *bgammon.GameState) *History {
+	h := &History{
+		Player1:     state.Player1.Name,
+		Player2:     state.Player2.Name,
+		Points:      state.Points,
+		Started:     state.Started,
+		doubleValue: state.DoubleValue,
+	}
+	h.newGame(state)
+	return h
+}
+
+// Matches returns whether the history is the history of the match in the
+// specified state.
+func (h *History) Matches(state *bgammon.GameState) bool {
+	return h != nil && h.Player1 == state.Player1.Name && h.Player2 == state.Player2.Name && h.Points == state.Points && h.Started.Equal(state.Started)
+}
+
+func (h *History) newGame(state *bgammon.GameState) {
+	h.Games = append(h.Games, &HistoryGame{
+		Number: len(h.Games) + 1,
+		Score1: state.Player1.Points,
+		Score2: state.Player2.Points,
+	})
+	h.doubleOffered = false
+	h.doubleValue = state.DoubleValue
+}
+
+// game returns the game in progress.
+func (h *History) game() *HistoryGame {
+	return h.Games[len(h.Games)-1]
+}
+
+func (h *History) last() *HistoryEntry {
+	g := h.game()
+	if len(g.Entries) == 0 {
+		return nil
+	}
+	return g.Entries[len(g.Entries)-1]
+}
+
+func (h *History) add(entry *HistoryEntry) {
+	g := h.game()
+	entry.Turn = 1
+	if last := h.last(); last != nil {
+		entry.Turn = last.Turn
+		// The player who doubled rolls during the same turn after the
+		// double is accepted.
+		if entry.Action == HistoryDouble || (entry.Action == HistoryMove && last.Action != HistoryTake) {
+			entry.Turn++
+		}
+	}
+	g.Entries = append(g.Entries, entry)
+}
+
+// Entries returns every action taken during the match.
+func (h *History) Entries() []*HistoryEntry {
+	if h == nil {
+		return nil
+	}
+	var entries []*HistoryEntry
+	for _, g := range h.Games {
+		entries = append(entries, g.Entries...)
+	}
+	return entries
+}
+
+// playerNumber returns the number of the player with the specified name.
+func (h *History) playerNumber(name string) int {
+	switch name {
+	case h.Player1:
+		return 1
+	case h.Player2:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// PlayerName returns the name of the player with the specified number.
+func (h *History) PlayerName(number int) string {
+	if number == 2 {
+		return h.Player2
+	}
+	return h.Player1
+}
+
+// RecordBoard records the doubles offered and accepted since the last game
+// state, and the position after the last action. A new game is started after
+// the previous game has finished.
+func (h *History) RecordBoard(state *bgammon.GameState) {
+	if h.game().Finished {
+		if state.Winner != 0 {
+			return
+		}
+		h.newGame(state)
+	}
+
+	if state.DoubleOffered && !h.doubleOffered {
+		h.add(&HistoryEntry{
+			Action: HistoryDouble,
+			Player: state.DoublePlayer,
+			Value:  state.DoubleValue * 2,
+		})
+		h.doublePlayer = state.DoublePlayer
+	} else if !state.DoubleOffered && h.doubleOffered && state.DoubleValue > h.doubleValue {
+		h.add(&HistoryEntry{
+			Action: HistoryTake,
+			Player: opponent(h.doublePlayer),
+			Value:  state.DoubleValue,
+		})
+	}
+	h.doubleOffered = state.DoubleOffered
+	h.doubleValue = state.DoubleValue
+
+	if last := h.last(); last != nil && last.Action != HistoryWin {
+		last.State = CopyState(state)
+	}
+}
+
+// RecordRolled records the dice rolled by a player. Opening rolls are
+// recorded with the first move.
+func (h *History) RecordRolled(state *bgammon.GameState, ev *bgammon.EventRolled) {
+	if state.Turn == 0 {
+		return
+	}
+	h.add(&HistoryEntry{
+		Action: HistoryMove,
+		Player: h.playerNumber(ev.Player),
+		Roll1:  ev.Roll1,
+		Roll2:  ev.Roll2,
+		State:  CopyState(state),
+	})
+}
+
+// RecordMoved records the checkers moved by a player. Moves which are undone
+// are removed. The second player receives moves from their own side of the
+// board, which are flipped back before they are recorded.
+func (h *History) RecordMoved(state *bgammon.GameState, ev *bgammon.EventMoved) {
+	player := h.playerNumber(ev.Player)
+	last := h.last()
+	if last == nil || last.Action != HistoryMove || last.Player != player {
+		last = &HistoryEntry{
+			Action: HistoryMove,
+			Player: player,
+			Roll1:  state.Roll1,
+			Roll2:  state.Roll2,
+		}
+		h.add(last)
+	}
+	for _, move := range ev.Moves {
+		move = []int{serverSpace(move[0], state.PlayerNumber), serverSpace(move[1], state.PlayerNumber)}
+		if n := len(last.Moves); n > 0 && last.Moves[n-1][0] == move[1] && last.Moves[n-1][1] == move[0] {
+			last.Moves = last.Moves[:n-1]
+			continue
+		}
+		last.Moves = append(last.Moves, []int{move[0], move[1]})
+	}
+}
+
+// RecordWin records the end of the game in progress, and a double declined
+// before it ended. False is returned when the game has already ended.
+func (h *History) RecordWin(state *bgammon.GameState, ev *bgammon.EventWin) bool {
+	if h.game().Finished {
+		return false
+	}
+	if h.doubleOffered {
+		h.add(&HistoryEntry{
+			Action: HistoryDrop,
+			Player: opponent(h.doublePlayer),
+		})
+		h.doubleOffered = false
+	}
+	h.add(&HistoryEntry{
+		Action: HistoryWin,
+		Player: h.playerNumber(ev.Player),
+		Value:  ev.Points,
+		State:  CopyState(state),
+	})
+	h.game().Finished = true
+	return true
+}
+
+// CopyState returns a copy of a game state which is not modified when the
+// live game state changes.
+func CopyState(state *bgammon.GameState) *bgammon.GameState {
+	g := *state.Game
+	g.Board = append([]int(nil), state.Board...)
+	g.Moves = nil
+	return &bgammon.GameState{
+		Game:         &g,
+		PlayerNumber: state.PlayerNumber,
+		Spectating:   state.Spectating,
+	}
+}
+
+// serverSpace returns a space received by a player as numbered by the server.
+// The second player is sent the board from their own side.
+func serverSpace(space int, player int) int {
+	if player != 2 {
+		return space
+	}
+	switch space {
+	case bgammon.SpaceHomePlayer:
+		return bgammon.SpaceHomeOpponent
+	case bgammon.SpaceHomeOpponent:
+		return bgammon.SpaceHomePlayer
+	case bgammon.SpaceBarPlayer:
+		return bgammon.SpaceBarOpponent
+	case bgammon.SpaceBarOpponent:
+		return bgammon.SpaceBarPlayer
+	}
+	return 25 - space
+}
+
+func opponent(player int) int {
+	if player == 1 {
+		return 2
+	}
+	return 1
+}
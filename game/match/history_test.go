@@ -0,0 +1,135 @@
+package match
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+)
+
+func TestRecord(t *testing.T) {
+	g := bgammon.NewGame()
+	g.Player1 = bgammon.Player{Number: 1, Name: "Alice"}
+	g.Player2 = bgammon.Player{Number: 2, Name: "Bob"}
+	g.Points = 1
+	g.DoubleValue = 1
+	g.Started = time.Date(2026, 10, 17, 12, 30, 0, 0, time.Local)
+	state := &bgammon.GameState{Game: g, PlayerNumber: 1}
+
+	var h *History
+	if h.Matches(state) {
+		t.Fatal("expected nil history not to match")
+	}
+	h = NewHistory(state)
+	if !h.Matches(state) {
+		t.Fatal("expected history to match the state it was created from")
+	}
+
+	rolled := func(player string, roll1 int, roll2 int) {
+		// Each player rolls a single die to determine who moves first.
+		if roll1 != 0 {
+			g.Roll1 = roll1
+		}
+		if roll2 != 0 {
+			g.Roll2 = roll2
+		}
+		ev := &bgammon.EventRolled{Roll1: roll1, Roll2: roll2}
+		ev.Player = player
+		h.RecordRolled(state, ev)
+	}
+	moved := func(player string, moves ...[]int) {
+		ev := &bgammon.EventMoved{Moves: moves}
+		ev.Player = player
+		h.RecordMoved(state, ev)
+		h.RecordBoard(state)
+	}
+
+	// Opening rolls are recorded with the first move, and moves which are
+	// undone are removed.
+	rolled("Alice", 3, 0)
+	rolled("Bob", 0, 1)
+	g.Turn = 1
+	moved("Alice", []int{8, 5})
+	moved("Alice", []int{5, 8})
+	moved("Alice", []int{8, 5}, []int{6, 5})
+
+	g.Turn = 2
+	rolled("Bob", 6, 4)
+	moved("Bob", []int{1, 11})
+
+	// Alice doubles and Bob takes. Alice then rolls during the same turn.
+	g.DoubleOffered, g.DoublePlayer = true, 1
+	h.RecordBoard(state)
+	g.DoubleOffered, g.DoublePlayer, g.DoubleValue = false, 2, 2
+	h.RecordBoard(state)
+	g.Turn = 1
+	rolled("Alice", 2, 2)
+
+	// Bob redoubles and Alice drops.
+	g.Turn = 2
+	g.DoubleOffered, g.DoublePlayer = true, 2
+	h.RecordBoard(state)
+	g.Winner = 2
+	win := &bgammon.EventWin{Points: 2}
+	win.Player = "Bob"
+	if !h.RecordWin(state, win) {
+		t.Fatal("expected win to be recorded")
+	} else if h.RecordWin(state, win) {
+		t.Fatal("expected win to be recorded once")
+	}
+
+	var actions []string
+	for _, entry := range h.Entries() {
+		actions = append(actions, fmt.Sprintf("%d.%d:%s", entry.Turn, entry.Player, strings.TrimSpace(matAction(entry))))
+	}
+	expected := fmt.Sprint([]string{
+		"1.1:31: 8/5 6/5",
+		"2.2:64: 24/14",
+		"3.1:Doubles => 2",
+		"3.2:Takes",
+		"3.1:22:",
+		"4.2:Doubles => 4",
+		"4.1:Drops",
+		"4.2:Wins 2 points",
+	})
+	if fmt.Sprint(actions) != expected {
+		t.Fatalf("expected actions %s, got %s", expected, actions)
+	}
+	if entries := h.Entries(); entries[0].State == nil || entries[0].State.Roll1 != 3 {
+		t.Fatal("expected position after moving to be recorded")
+	} else if score1, score2 := h.Score(); score1 != 0 || score2 != 2 || !h.Finished() {
+		t.Fatalf("expected Bob to win the match, score %d-%d", score1, score2)
+	}
+
+	// A new game is started once the state of the next game is received.
+	h.RecordBoard(state)
+	if len(h.Games) != 1 {
+		t.Fatal("expected no game to be started while the game is over")
+	}
+	g.Winner, g.Turn, g.DoubleValue, g.DoublePlayer, g.DoubleOffered = 0, 0, 1, 0, false
+	g.Player2.Points = 2
+	h.RecordBoard(state)
+	if len(h.Games) != 2 || h.Games[1].Number != 2 || h.Games[1].Score2 != 2 {
+		t.Fatalf("expected second game to be started, got %+v", h.Games)
+	}
+}
+
+func TestRecordMovedAsPlayer2(t *testing.T) {
+	g := bgammon.NewGame()
+	g.Player1 = bgammon.Player{Number: 1, Name: "Alice"}
+	g.Player2 = bgammon.Player{Number: 2, Name: "Bob"}
+	g.Turn, g.Roll1, g.Roll2 = 2, 6, 4
+	state := &bgammon.GameState{Game: g, PlayerNumber: 2}
+	h := NewHistory(state)
+
+	// Bob is sent moves from his own side of the board.
+	ev := &bgammon.EventMoved{Moves: [][]int{{24, 18}, {18, 14}, {6, bgammon.SpaceHomePlayer}}}
+	ev.Player = "Bob"
+	h.RecordMoved(state, ev)
+	expected := [][]int{{1, 7}, {7, 11}, {19, bgammon.SpaceHomeOpponent}}
+	if entries := h.Entries(); len(entries) != 1 || fmt.Sprint(entries[0].Moves) != fmt.Sprint(expected) {
+		t.Fatalf("expected moves %v, got %+v", expected, entries)
+	}
+}
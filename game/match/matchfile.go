@@ -0,0 +1,533 @@
+package match
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+)
+
+var (
+	matTagPattern    = regexp.MustCompile(`^;\s*\[(.+?)\s+"(.*)"\]`)
+	matLengthPattern = regexp.MustCompile(`^\s*(\d+)\s+point match`)
+	matGamePattern   = regexp.MustCompile(`^\s*Game\s+(\d+)`)
+	matScorePattern  = regexp.MustCompile(`^\s*(.*?)\s*:\s*(\d+)\s+(.*?)\s*:\s*(\d+)\s*$`)
+	matTurnPattern   = regexp.MustCompile(`^\s*\d+\)`)
+	matTokenPattern  = regexp.MustCompile(`\S+`)
+	matRollPattern   = regexp.MustCompile(`^([1-6])([1-6]):$`)
+	matMovePattern   = regexp.MustCompile(`(?i)^(bar|\d+)((?:/(?:bar|off|\d+)\*?)+)(?:\((\d)\))?$`)
+)
+
+// matchReader builds the history of a match read from a file. The position
+// after each action is recorded so the match may be replayed on the board.
+type matchReader struct {
+	h    *History
+	game *bgammon.Game
+}
+
+func newMatchReader() *matchReader {
+	return &matchReader{
+		h: &History{},
+	}
+}
+
+func (r *matchReader) newGame(score1 int, score2 int) {
+	g := bgammon.NewGame()
+	g.Started = r.h.Started
+	g.Player1 = bgammon.Player{Number: 1, Name: r.h.Player1, Points: score1}
+	g.Player2 = bgammon.Player{Number: 2, Name: r.h.Player2, Points: score2}
+	g.Points = r.h.Points
+	g.DoubleValue = 1
+	r.game = g
+
+	r.h.Games = append(r.h.Games, &HistoryGame{
+		Number: len(r.h.Games) + 1,
+		Score1: score1,
+		Score2: score2,
+	})
+}
+
+// add records an action and the position after it.
+func (r *matchReader) add(entry *HistoryEntry) {
+	entry.State = CopyState(&bgammon.GameState{
+		Game:         r.game,
+		PlayerNumber: 1,
+		Spectating:   true,
+	})
+	r.h.add(entry)
+}
+
+// move plays the moves of a player after rolling the dice. The moves must be
+// legal and play as many dice as possible.
+func (r *matchReader) move(player int, roll1 int, roll2 int, moves [][]int) error {
+	if r.game == nil {
+		return errors.New("move before start of game")
+	}
+	g := r.game
+	g.Turn = player
+	g.Roll1, g.Roll2 = roll1, roll2
+	g.Moves = nil
+	g.DoubleOffered = false
+	var played [][]int
+	for _, move := range moves {
+		split := splitMove(g, move[0], move[1])
+		if split == nil {
+			return fmt.Errorf("game %d: illegal move %d/%d with roll %d-%d", len(r.h.Games), matSpace(move[0], player), matSpace(move[1], player), roll1, roll2)
+		}
+		g.AddMoves(split, false)
+		played = append(played, split...)
+	}
+	if len(g.LegalMoves(false)) != 0 {
+		return fmt.Errorf("game %d: roll %d-%d not played fully", len(r.h.Games), roll1, roll2)
+	}
+	g.Moves = nil
+	r.add(&HistoryEntry{
+		Action: HistoryMove,
+		Player: player,
+		Roll1:  roll1,
+		Roll2:  roll2,
+		Moves:  played,
+	})
+	return nil
+}
+
+func (r *matchReader) cube(action HistoryAction, player int, value int) error {
+	if r.game == nil {
+		return errors.New("double before start of game")
+	}
+	g := r.game
+	switch action {
+	case HistoryDouble:
+		if value == 0 {
+			value = g.DoubleValue * 2
+		}
+		g.DoubleOffered = true
+		g.DoublePlayer = player
+	case HistoryTake:
+		g.DoubleOffered = false
+		g.DoubleValue *= 2
+		g.DoublePlayer = player
+		value = g.DoubleValue
+	case HistoryDrop:
+		g.DoubleOffered = false
+	}
+	r.add(&HistoryEntry{
+		Action: action,
+		Player: player,
+		Value:  value,
+	})
+	return nil
+}
+
+func (r *matchReader) win(player int, points int) error {
+	if r.game == nil {
+		return errors.New("win before start of game")
+	}
+	g := r.game
+	g.Winner = player
+	if player == 1 {
+		g.Player1.Points += points
+	} else {
+		g.Player2.Points += points
+	}
+	r.add(&HistoryEntry{
+		Action: HistoryWin,
+		Player: player,
+		Value:  points,
+	})
+	r.h.game().Finished = true
+	return nil
+}
+
+// splitMove returns the legal moves which move a checker from one space to
+// another. Match files may combine the moves of a checker using more than one
+// die, such as 24/13 with a roll of 6-5. Nil is returned when the checker may
+// not be moved.
+func splitMove(g *bgammon.Game, from int, to int) [][]int {
+	for _, move := range g.LegalMoves(false) {
+		if move[0] != from {
+			continue
+		} else if move[1] == to {
+			return [][]int{move}
+		}
+		space := matSpace(move[1], g.Turn)
+		if space <= matSpace(to, g.Turn) || space >= matSpace(from, g.Turn) {
+			continue
+		}
+		next := g.Copy()
+		if ok, _ := next.AddMoves([][]int{move}, false); !ok {
+			continue
+		}
+		if moves := splitMove(next, move[1], to); moves != nil {
+			return append([][]int{move}, moves...)
+		}
+	}
+	return nil
+}
+
+// fromMatSpace returns the space written in a match file from the
+// perspective of a player. It is the inverse of matSpace.
+func fromMatSpace(space int, player int) int {
+	switch {
+	case space >= 25 && player == 2:
+		return bgammon.SpaceBarOpponent
+	case space >= 25:
+		return bgammon.SpaceBarPlayer
+	case space <= 0 && player == 2:
+		return bgammon.SpaceHomeOpponent
+	case space <= 0:
+		return bgammon.SpaceHomePlayer
+	case player == 2:
+		return 25 - space
+	default:
+		return space
+	}
+}
+
+// parseMatMoves parses the moves of a player written in a Jellyfish match
+// file, such as 24/18* 13/9 or bar/22 6/off(2).
+func parseMatMoves(token string, player int) ([][]int, bool) {
+	m := matMovePattern.FindStringSubmatch(token)
+	if m == nil {
+		return nil, false
+	}
+	space := func(s string) int {
+		switch strings.ToLower(strings.TrimSuffix(s, "*")) {
+		case "bar":
+			return 25
+		case "off":
+			return 0
+		}
+		v, _ := strconv.Atoi(strings.TrimSuffix(s, "*"))
+		return v
+	}
+	spaces := []int{space(m[1])}
+	for _, s := range strings.Split(m[2][1:], "/") {
+		spaces = append(spaces, space(s))
+	}
+	count := 1
+	if m[3] != "" {
+		count, _ = strconv.Atoi(m[3])
+	}
+	var moves [][]int
+	for i := 0; i < count; i++ {
+		for j := 1; j < len(spaces); j++ {
+			moves = append(moves, []int{fromMatSpace(spaces[j-1], player), fromMatSpace(spaces[j], player)})
+		}
+	}
+	return moves, true
+}
+
+// readMat reads a Jellyfish text match (.mat).
+func readMat(buf []byte) (*History, error) {
+	r := newMatchReader()
+	var date, clock string
+	var gameStarted bool
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if m := matTagPattern.FindStringSubmatch(line); m != nil {
+			switch m[1] {
+			case "Player 1":
+				r.h.Player1 = m[2]
+			case "Player 2":
+				r.h.Player2 = m[2]
+			case "EventDate":
+				date = m[2]
+			case "EventTime":
+				clock = m[2]
+			}
+			continue
+		} else if strings.HasPrefix(strings.TrimSpace(line), ";") {
+			continue
+		} else if m := matLengthPattern.FindStringSubmatch(line); m != nil {
+			r.h.Points, _ = strconv.Atoi(m[1])
+			continue
+		} else if matGamePattern.MatchString(line) {
+			gameStarted = true
+			continue
+		} else if gameStarted {
+			m := matScorePattern.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: expected score of each player", lineNumber)
+			}
+			if r.h.Player1 == "" && r.h.Player2 == "" {
+				r.h.Player1, r.h.Player2 = m[1], m[3]
+			}
+			if date != "" && r.h.Started.IsZero() {
+				if clock == "" {
+					clock = "00.00"
+				}
+				r.h.Started, _ = time.ParseInLocation("2006.01.02 15.04", date+" "+clock, time.Local)
+			}
+			score1, _ := strconv.Atoi(m[2])
+			score2, _ := strconv.Atoi(m[4])
+			r.newGame(score1, score2)
+			gameStarted = false
+			continue
+		}
+
+		offset := 0
+		if loc := matTurnPattern.FindStringIndex(line); loc != nil {
+			offset = loc[1]
+		}
+		tokens := matTokenPattern.FindAllStringIndex(line[offset:], -1)
+		actions := 0
+		player := func(start int) int {
+			if actions == 0 && offset+start < matRightColumn {
+				return 1
+			}
+			return 2
+		}
+		for i := 0; i < len(tokens); i++ {
+			token := line[offset+tokens[i][0] : offset+tokens[i][1]]
+			p := player(tokens[i][0])
+			var err error
+			if m := matRollPattern.FindStringSubmatch(token); m != nil {
+				var moves [][]int
+				for ; i+1 < len(tokens); i++ {
+					next := line[offset+tokens[i+1][0] : offset+tokens[i+1][1]]
+					nextMoves, ok := parseMatMoves(next, p)
+					if !ok {
+						break
+					}
+					moves = append(moves, nextMoves...)
+				}
+				roll1, _ := strconv.Atoi(m[1])
+				roll2, _ := strconv.Atoi(m[2])
+				err = r.move(p, roll1, roll2, moves)
+			} else {
+				switch strings.ToLower(token) {
+				case "doubles":
+					value := 0
+					if i+2 < len(tokens) && line[offset+tokens[i+1][0]:offset+tokens[i+1][1]] == "=>" {
+						value, _ = strconv.Atoi(line[offset+tokens[i+2][0] : offset+tokens[i+2][1]])
+						i += 2
+					}
+					err = r.cube(HistoryDouble, p, value)
+				case "takes", "accepts":
+					err = r.cube(HistoryTake, p, 0)
+				case "drops", "passes", "rejects":
+					err = r.cube(HistoryDrop, p, 0)
+				case "wins":
+					points := 1
+					if i+1 < len(tokens) {
+						points, _ = strconv.Atoi(line[offset+tokens[i+1][0] : offset+tokens[i+1][1]])
+						i++
+					}
+					err = r.win(p, points)
+				default:
+					continue
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+			}
+			actions++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	} else if len(r.h.Games) == 0 {
+		return nil, errors.New("no games found")
+	}
+	return r.h, nil
+}
+
+// sgfNode is a node of an SGF game tree. Each property may have several
+// values.
+type sgfNode map[string][]string
+
+// parseSGF parses the main line of each game tree in an SGF collection.
+// Variations are skipped.
+func parseSGF(buf []byte) ([][]sgfNode, error) {
+	var trees [][]sgfNode
+	var node sgfNode
+	var ident []byte
+	var lastIdent string
+	depth := 0
+	for i := 0; i < len(buf); i++ {
+		c := buf[i]
+		switch {
+		case c == '(':
+			depth++
+			if depth == 1 {
+				trees = append(trees, nil)
+			}
+		case c == ')':
+			depth--
+			if depth < 0 {
+				return nil, errors.New("unexpected )")
+			}
+		case c == ';' && depth == 1:
+			node = sgfNode{}
+			trees[len(trees)-1] = append(trees[len(trees)-1], node)
+			ident, lastIdent = nil, ""
+		case c == '[':
+			var value []byte
+			for i++; i < len(buf) && buf[i] != ']'; i++ {
+				if buf[i] == '\\' && i+1 < len(buf) {
+					i++
+				}
+				value = append(value, buf[i])
+			}
+			if i == len(buf) {
+				return nil, errors.New("unterminated property value")
+			}
+			if depth != 1 || node == nil {
+				continue
+			}
+			if len(ident) > 0 {
+				lastIdent = string(ident)
+				ident = nil
+			}
+			if lastIdent != "" {
+				node[lastIdent] = append(node[lastIdent], string(value))
+			}
+		case c >= 'A' && c <= 'Z':
+			ident = append(ident, c)
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unterminated game tree")
+	}
+	return trees, nil
+}
+
+// readSGF reads a match in the Smart Game Format (.sgf). Player 1 is black
+// and player 2 is white, as written by WriteSGF.
+func readSGF(buf []byte) (*History, error) {
+	trees, err := parseSGF(buf)
+	if err != nil {
+		return nil, err
+	}
+	r := newMatchReader()
+	value := func(n sgfNode, ident string) string {
+		if len(n[ident]) == 0 {
+			return ""
+		}
+		return n[ident][0]
+	}
+	for _, tree := range trees {
+		if len(tree) == 0 {
+			continue
+		}
+		root := tree[0]
+		if gm := value(root, "GM"); gm != "" && gm != "6" {
+			return nil, errors.New("not a backgammon game")
+		}
+		r.h.Player1, r.h.Player2 = value(root, "PB"), value(root, "PW")
+		if started, err := time.ParseInLocation("2006-01-02", value(root, "DT"), time.Local); err == nil {
+			r.h.Started = started
+		}
+		var score1, score2 int
+		for _, info := range root["MI"] {
+			kv := strings.SplitN(info, ":", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			v, _ := strconv.Atoi(kv[1])
+			switch kv[0] {
+			case "length":
+				r.h.Points = v
+			case "bs":
+				score1 = v
+			case "ws":
+				score2 = v
+			}
+		}
+		r.newGame(score1, score2)
+
+		for _, node := range tree[1:] {
+			for _, color := range []string{"B", "W"} {
+				action, ok := node[color]
+				if !ok || len(action) == 0 {
+					continue
+				}
+				player := 1
+				if color == "W" {
+					player = 2
+				}
+				err := readSGFAction(r, player, strings.TrimSpace(action[0]))
+				if err != nil {
+					return nil, fmt.Errorf("game %d: %s", len(r.h.Games), err)
+				}
+			}
+		}
+
+		if result := value(root, "RE"); len(result) > 2 && result[1] == '+' {
+			player := 1
+			if result[0] == 'W' {
+				player = 2
+			}
+			points, _ := strconv.Atoi(strings.TrimRight(result[2:], "RrGgBb"))
+			if points == 0 {
+				points = 1
+			}
+			r.win(player, points)
+		}
+	}
+	if len(r.h.Games) == 0 {
+		return nil, errors.New("no games found")
+	}
+	return r.h, nil
+}
+
+// readSGFAction reads the value of a move property of an SGF node.
+func readSGFAction(r *matchReader, player int, action string) error {
+	switch strings.ToLower(action) {
+	case "double":
+		return r.cube(HistoryDouble, player, 0)
+	case "take":
+		return r.cube(HistoryTake, player, 0)
+	case "drop":
+		return r.cube(HistoryDrop, player, 0)
+	}
+	if len(action) < 2 || len(action)%2 != 0 || action[0] < '1' || action[0] > '6' || action[1] < '1' || action[1] > '6' {
+		return fmt.Errorf("invalid move %s", action)
+	}
+	space := func(c byte) (int, error) {
+		switch {
+		case c == 'y':
+			return 25, nil
+		case c == 'z':
+			return 0, nil
+		case c >= 'a' && c <= 'x':
+			return int(c-'a') + 1, nil
+		}
+		return 0, fmt.Errorf("invalid move %s", action)
+	}
+	var moves [][]int
+	for i := 2; i < len(action); i += 2 {
+		from, err := space(action[i])
+		if err != nil {
+			return err
+		}
+		to, err := space(action[i+1])
+		if err != nil {
+			return err
+		}
+		moves = append(moves, []int{fromMatSpace(from, player), fromMatSpace(to, player)})
+	}
+	return r.move(player, int(action[0]-'0'), int(action[1]-'0'), moves)
+}
+
+// Read reads a match file. SGF files are detected by their name or content,
+// and all other files are read as Jellyfish text matches.
+func Read(name string, buf []byte) (*History, error) {
+	if strings.EqualFold(path.Ext(name), "."+FormatSGF) || bytes.HasPrefix(bytes.TrimSpace(buf), []byte("(;")) {
+		return readSGF(buf)
+	}
+	return readMat(buf)
+}
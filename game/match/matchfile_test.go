@@ -0,0 +1,268 @@
+package match
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
+)
+
+// testMat is a match in the style written by GNU Backgammon. Moves using more
+// than one die are combined, and moves of several checkers between the same
+// spaces are counted.
+const testMat = `; [Site "Example"]
+; [Player 1 "Alice"]
+; [Player 2 "Bob"]
+; [EventDate "2026.10.17"]
+; [EventTime "12.30"]
+
+ 1 point match
+
+ Game 1
+ Alice : 0                          Bob : 0
+  1) 64: 24/18 13/9                 61: 13/7* 8/7
+  2) 55: bar/15 13/8(2)              Doubles => 2
+  3)  Drops
+                                    Wins 1 point
+`
+
+func TestReadMat(t *testing.T) {
+	h, err := Read("match.mat", []byte(testMat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Player1 != "Alice" || h.Player2 != "Bob" || h.Points != 1 {
+		t.Fatalf("unexpected match: %+v", h)
+	} else if started := time.Date(2026, 10, 17, 12, 30, 0, 0, time.Local); !h.Started.Equal(started) {
+		t.Fatalf("expected match started at %s, got %s", started, h.Started)
+	}
+
+	var actions []string
+	for _, entry := range h.Entries() {
+		actions = append(actions, fmt.Sprintf("%d.%d:%s", entry.Turn, entry.Player, strings.TrimSpace(matAction(entry))))
+	}
+	expected := fmt.Sprint([]string{
+		"1.1:64: 24/18 13/9",
+		"2.2:61: 13/7 8/7",
+		"3.1:55: 25/20 20/15 13/8 13/8",
+		"4.2:Doubles => 2",
+		"4.1:Drops",
+		"4.2:Wins 1 point",
+	})
+	if fmt.Sprint(actions) != expected {
+		t.Fatalf("expected actions %s, got %s", expected, actions)
+	}
+
+	entries := h.Entries()
+	board := entries[2].State.Board
+	for space, checkers := range map[int]int{24: 1, 18: -2, 15: 1, 13: 2, 12: -4, 9: 1, 8: 5, 17: -2, bgammon.SpaceBarPlayer: 0} {
+		if board[space] != checkers {
+			t.Errorf("expected %d checkers on space %d, got %d: %v", checkers, space, board[space], board)
+		}
+	}
+	if state := entries[len(entries)-1].State; state.Winner != 2 || state.Player2.Points != 1 || !h.Finished() {
+		t.Fatalf("expected Bob to win the match, got %+v", state.Game)
+	}
+}
+
+func TestReadMatIllegalMove(t *testing.T) {
+	illegal := strings.Replace(testMat, "13/7* 8/7", "13/7* 8/2", 1)
+	if _, err := Read("match.mat", []byte(illegal)); err == nil || !strings.Contains(err.Error(), "line 11: game 1: illegal move 8/2 with roll 6-1") {
+		t.Fatalf("expected illegal move error, got %v", err)
+	}
+	partial := strings.Replace(testMat, "13/7* 8/7", "13/7*", 1)
+	if _, err := Read("match.mat", []byte(partial)); err == nil || !strings.Contains(err.Error(), "roll 6-1 not played fully") {
+		t.Fatalf("expected error when a roll is not played fully, got %v", err)
+	}
+}
+
+func TestWriteMat(t *testing.T) {
+	h, err := Read("match.mat", []byte(testMat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	err = h.WriteMat(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `; [Player 1 "Alice"]
+; [Player 2 "Bob"]
+; [EventDate "2026.10.17"]
+; [EventTime "12.30"]
+; [Variation "Backgammon"]
+; [Transcriber "` + client.APPNAME + `"]
+
+ 1 point match
+
+ Game 1
+ Alice : 0                        Bob : 0
+  1) 64: 24/18 13/9               61: 13/7 8/7
+  2) 55: 25/20 20/15 13/8 13/8     Doubles => 2
+  3)  Drops
+                                  Wins 1 point
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected match file:\n%s\nexpected:\n%s", buf, expected)
+	}
+}
+
+// playRandomMatch returns the history of a match played with random legal
+// moves. The first game is played until a player bears off every checker,
+// with a double taken along the way. The winner of the first game wins the
+// match when the double offered during the second game is dropped.
+func playRandomMatch(t *testing.T, seed int64) *History {
+	t.Helper()
+	random := rand.New(rand.NewSource(seed))
+	r := newMatchReader()
+	r.h.Player1, r.h.Player2 = "Alice", "Bob"
+	r.h.Points = 3
+	r.h.Started = time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	turn := func(player int) {
+		roll1, roll2 := random.Intn(6)+1, random.Intn(6)+1
+		g := r.game.Copy()
+		g.Turn, g.Roll1, g.Roll2, g.Moves = player, roll1, roll2, nil
+		var moves [][]int
+		for {
+			legal := g.LegalMoves(false)
+			if len(legal) == 0 {
+				break
+			}
+			move := legal[random.Intn(len(legal))]
+			g.AddMoves([][]int{move}, false)
+			moves = append(moves, move)
+		}
+		check(r.move(player, roll1, roll2, moves))
+	}
+
+	r.newGame(0, 0)
+	var winner int
+	for player, turns := 1, 1; winner == 0; player, turns = opponent(player), turns+1 {
+		if turns == 5 {
+			check(r.cube(HistoryDouble, player, 0))
+			check(r.cube(HistoryTake, opponent(player), 0))
+		}
+		turn(player)
+		home := bgammon.SpaceHomePlayer
+		if player == 2 {
+			home = bgammon.SpaceHomeOpponent
+		}
+		if r.game.Board[home] == 15 || r.game.Board[home] == -15 {
+			winner = player
+			check(r.win(winner, r.game.DoubleValue))
+		}
+	}
+
+	score1, score2 := r.h.Score()
+	r.newGame(score1, score2)
+	turn(opponent(winner))
+	turn(winner)
+	check(r.cube(HistoryDouble, winner, 0))
+	check(r.cube(HistoryDrop, opponent(winner), 0))
+	check(r.win(winner, 1))
+	return r.h
+}
+
+// compareHistory compares the actions and positions of two matches.
+func compareHistory(t *testing.T, expected *History, h *History) {
+	t.Helper()
+	if h.Player1 != expected.Player1 || h.Player2 != expected.Player2 || h.Points != expected.Points || !h.Started.Equal(expected.Started) {
+		t.Fatalf("expected match %+v, got %+v", expected, h)
+	} else if len(h.Games) != len(expected.Games) {
+		t.Fatalf("expected %d games, got %d", len(expected.Games), len(h.Games))
+	}
+	for i, g := range h.Games {
+		e := expected.Games[i]
+		if g.Number != e.Number || g.Score1 != e.Score1 || g.Score2 != e.Score2 || g.Finished != e.Finished {
+			t.Fatalf("game %d: expected %+v, got %+v", i+1, e, g)
+		} else if len(g.Entries) != len(e.Entries) {
+			t.Fatalf("game %d: expected %d actions, got %d", i+1, len(e.Entries), len(g.Entries))
+		}
+		for j, entry := range g.Entries {
+			want := e.Entries[j]
+			if entry.Action != want.Action || entry.Player != want.Player || entry.Turn != want.Turn || entry.Roll1 != want.Roll1 || entry.Roll2 != want.Roll2 || entry.Value != want.Value || !reflect.DeepEqual(entry.Moves, want.Moves) {
+				t.Fatalf("game %d action %d: expected %+v, got %+v", i+1, j+1, want, entry)
+			} else if !reflect.DeepEqual(entry.State.Board, want.State.Board) || entry.State.DoubleValue != want.State.DoubleValue || entry.State.Winner != want.State.Winner {
+				t.Fatalf("game %d action %d: expected position %v, got %v", i+1, j+1, want.State.Board, entry.State.Board)
+			}
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for seed := int64(1); seed <= 10; seed++ {
+		h := playRandomMatch(t, seed)
+		if !h.Finished() {
+			score1, score2 := h.Score()
+			t.Fatalf("seed %d: expected finished match, score %d-%d", seed, score1, score2)
+		}
+		for _, format := range []string{FormatMat, FormatSGF} {
+			buf := &bytes.Buffer{}
+			err := h.Write(buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			read, err := Read(h.FileName(format), buf.Bytes())
+			if err != nil {
+				t.Fatalf("seed %d: failed to read %s:\n%s\n%s", seed, format, err, buf)
+			}
+			compareHistory(t, h, read)
+
+			// Writing the match read must result in the same file.
+			again := &bytes.Buffer{}
+			err = read.Write(again, format)
+			if err != nil {
+				t.Fatal(err)
+			} else if again.String() != buf.String() {
+				t.Fatalf("seed %d: %s differs after reading and writing:\n%s\nexpected:\n%s", seed, format, again, buf)
+			}
+		}
+	}
+}
+
+func TestReadSGF(t *testing.T) {
+	sgf := `(;FF[4]GM[6]CA[UTF-8]AP[GNU Backgammon:1.07]MI[length:1][game:0][bs:0][ws:0]PB[Alice]PW[Bob \] Smith]DT[2026-10-17]RE[B+1]
+;B[64xrmi]
+;W[61mghg]
+;B[double]
+;W[drop]
+(;B[52aaaa])
+)
+`
+	h, err := Read("match.txt", []byte(sgf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Player1 != "Alice" || h.Player2 != "Bob ] Smith" || h.Points != 1 {
+		t.Fatalf("unexpected match: %+v", h)
+	}
+	var actions []string
+	for _, entry := range h.Entries() {
+		actions = append(actions, fmt.Sprintf("%d:%s", entry.Player, strings.TrimSpace(matAction(entry))))
+	}
+	// Variations are skipped.
+	expected := fmt.Sprint([]string{"1:64: 24/18 13/9", "2:61: 13/7 8/7", "1:Doubles => 2", "2:Drops", "1:Wins 1 point"})
+	if fmt.Sprint(actions) != expected {
+		t.Fatalf("expected actions %s, got %s", expected, actions)
+	}
+
+	for _, invalid := range []string{"(;GM[6]", "(;GM[1])", "(;GM[6];B[64zz])", "(;GM[6];B[7])"} {
+		if _, err := Read("match.sgf", []byte(invalid)); err == nil {
+			t.Errorf("%s: expected error", invalid)
+		}
+	}
+}
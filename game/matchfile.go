@@ -0,0 +1,87 @@
+package game
+
+import (
+	"errors"
+	"io/fs"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+	"code.rocket9labs.com/tslocum/boxcars/game/match"
+	"github.com/leonelquinteros/gotext"
+)
+
+// OpenMatch shows a match read from a file on the board. No connection to a
+// server is required. The board is closed by leaving the match.
+func (g *Game) OpenMatch(name string, buf []byte) error {
+	if g.loggedIn {
+		return errors.New("disconnect before opening a match file")
+	}
+	h, err := match.Read(name, buf)
+	if err != nil {
+		return err
+	}
+	entries := h.Entries()
+	if len(entries) == 0 {
+		return errors.New("no moves found")
+	}
+
+	b := g.Board
+	b.Lock()
+	b.gameState = match.CopyState(entries[len(entries)-1].State)
+	b.history = h
+	b.showHistory = true
+	b.dragging = nil
+	b.showHistoryEntry(entries[0])
+	b.Unlock()
+	b.recreateUIGrid()
+
+	g.viewingMatch = true
+	setViewBoard(true)
+	l("*** " + gotext.Get("Opened %s.", name))
+	return nil
+}
+
+// openDroppedFiles opens the first match file dropped on the window.
+func (g *Game) openDroppedFiles(files fs.FS) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		buf, err := fs.ReadFile(files, entry.Name())
+		if err != nil {
+			l("*** " + gotext.Get("Failed to open %s: %s", entry.Name(), err))
+			return
+		}
+		g.openMatchFile(entry.Name(), buf)
+		return
+	}
+}
+
+// openMatchFile opens a match file selected by the user, reporting any error.
+func (g *Game) openMatchFile(name string, buf []byte) {
+	err := g.OpenMatch(name, buf)
+	if err != nil {
+		l("*** " + gotext.Get("Failed to open %s: %s", name, err))
+	}
+}
+
+// closeMatch stops showing a match which was read from a file.
+func (g *Game) closeMatch() {
+	b := g.Board
+	b.Lock()
+	b.gameState = &bgammon.GameState{
+		Game: bgammon.NewGame(),
+	}
+	b.history = nil
+	b.viewEntry = nil
+	b.dragging = nil
+	b.processState()
+	b.updateHistoryList()
+	b.Unlock()
+
+	g.viewingMatch = false
+	setViewBoard(false)
+}
@@ -0,0 +1,9 @@
+//go:build !js || !wasm
+
+package game
+
+// selectOpenMatch asks the user to select a match file to open. Match files
+// are opened by dropping them on the window or via the -open flag instead.
+func (g *Game) selectOpenMatch() error {
+	return nil
+}
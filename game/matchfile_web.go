@@ -0,0 +1,40 @@
+//go:build js && wasm
+
+package game
+
+import (
+	"syscall/js"
+)
+
+// selectOpenMatch asks the user to select a match file to open.
+func (g *Game) selectOpenMatch() error {
+	input := js.Global().Get("document").Call("createElement", "input")
+	input.Set("type", "file")
+	input.Set("accept", ".mat,.sgf,.txt")
+
+	var onChange js.Func
+	onChange = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		onChange.Release()
+		files := input.Get("files")
+		if files.Get("length").Int() == 0 {
+			return nil
+		}
+		file := files.Index(0)
+		name := file.Get("name").String()
+
+		var onLoad js.Func
+		onLoad = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			onLoad.Release()
+			data := js.Global().Get("Uint8Array").New(args[0])
+			buf := make([]byte, data.Get("length").Int())
+			js.CopyBytesToGo(buf, data)
+			go g.openMatchFile(name, buf)
+			return nil
+		})
+		file.Call("arrayBuffer").Call("then", onLoad)
+		return nil
+	})
+	input.Call("addEventListener", "change", onChange)
+	input.Call("click")
+	return nil
+}
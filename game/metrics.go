@@ -0,0 +1,61 @@
+package game
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
+)
+
+// expectedFrameInterval is the interval between frames at 60 FPS. A frame is
+// counted as dropped when Draw is not called for more than twice as long.
+const expectedFrameInterval = time.Second / 60
+
+var (
+	framesDrawn    uint64
+	framesDropped  uint64
+	animationQueue int64 // Number of moves waiting to be animated.
+	lastDraw       time.Time
+)
+
+// countFrame is called whenever Draw is called. drawn is whether the screen
+// was redrawn or the previous frame was reused.
+func countFrame(drawn bool) {
+	now := time.Now()
+	// Longer pauses are caused by the window being hidden.
+	if elapsed := now.Sub(lastDraw); !lastDraw.IsZero() && elapsed < time.Second {
+		if missed := elapsed/expectedFrameInterval - 1; missed > 0 {
+			atomic.AddUint64(&framesDropped, uint64(missed))
+		}
+	}
+	lastDraw = now
+	if drawn {
+		atomic.AddUint64(&framesDrawn, 1)
+	}
+}
+
+// ServeMetrics serves client metrics in the Prometheus text format.
+func (g *Game) ServeMetrics(w http.ResponseWriter, r *http.Request) {
+	g.sessionLock.Lock()
+	clients := make([]*client.Client, len(g.sessions))
+	for i, s := range g.sessions {
+		clients[i] = s.client
+	}
+	g.sessionLock.Unlock()
+
+	m := &client.MetricsWriter{}
+	m.WriteClients(clients)
+
+	m.Header("boxcars_frames_drawn_total", "counter", "Number of frames drawn.")
+	m.Value("boxcars_frames_drawn_total", nil, atomic.LoadUint64(&framesDrawn))
+
+	m.Header("boxcars_frames_dropped_total", "counter", "Number of frames dropped because drawing took too long.")
+	m.Value("boxcars_frames_dropped_total", nil, atomic.LoadUint64(&framesDropped))
+
+	m.Header("boxcars_animation_queue_length", "gauge", "Number of moves waiting to be animated.")
+	m.Value("boxcars_animation_queue_length", nil, atomic.LoadInt64(&animationQueue))
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(m.Bytes())
+}
@@ -0,0 +1,128 @@
+package game
+
+import (
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
+	"code.rocket9labs.com/tslocum/boxcars/game/fakeserver"
+	"code.rocket9labs.com/tslocum/etk"
+	"github.com/leonelquinteros/gotext"
+)
+
+const (
+	offlineAddress  = "offline"  // Address of sessions playing offline.
+	offlineOpponent = "Computer" // Username of the computer opponent.
+)
+
+// PlayOffline starts a match against the built-in computer opponent. The
+// match is played on a fake server running within the client, which allows
+// the board and lobby to behave the same as when playing online. Another
+// match is created by the opponent whenever a match ends.
+func (g *Game) PlayOffline(strength client.Strength, points int) {
+	if g.loggedIn {
+		return
+	}
+	g.loggedIn = true
+
+	l("*** " + gotext.Get("Starting offline match against %s (%s)...", offlineOpponent, strength))
+
+	g.hideKeyboard()
+	g.setRoot(listGamesFrame)
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	server := fakeserver.NewServer()
+	server.Dice = func() int {
+		return 1 + r.Intn(6)
+	}
+
+	playerTransport, playerConn := client.NewPipeTransport()
+	opponentTransport, opponentConn := client.NewPipeTransport()
+
+	username := g.Username
+	if strings.EqualFold(username, offlineOpponent) {
+		username = ""
+	}
+	c := g.addSession(offlineAddress, username, "").client
+	c.NewTransport = func() client.Transport {
+		return playerTransport
+	}
+
+	g.Username = ""
+	g.Password = ""
+
+	computer := client.NewClient(offlineAddress, offlineOpponent, "")
+	computer.NewTransport = func() client.Transport {
+		return opponentTransport
+	}
+	bot := client.NewBot(computer, client.NewPubevalStrategy(strength), client.BotOptions{
+		Create: &client.MatchOptions{
+			Name:   gotext.Get("Offline match"),
+			Points: points,
+		},
+	})
+
+	// Join the first match once it has been created. The server is locked
+	// while the command is handled, so the match exists before the join
+	// command is handled.
+	var join sync.Once
+	server.Handler = func(conn *fakeserver.Conn, command string, args []string) (handled bool) {
+		if conn.Name == offlineOpponent && (command == "c" || command == "create") {
+			join.Do(func() {
+				c.Join(1, "")
+			})
+		}
+		return false
+	}
+
+	go func() {
+		server.ServeConn(playerConn)
+		// Stop the opponent once the player has disconnected.
+		computer.Disconnect()
+	}()
+	go server.ServeConn(opponentConn)
+	go func() {
+		err := bot.Run()
+		if err != nil {
+			log.Printf("error: computer opponent stopped: %s", err)
+		}
+	}()
+	go c.Connect()
+}
+
+func (g *Game) showOffline() error {
+	if g.offlinePoints.Text() == "" {
+		g.offlinePoints.Field.SetText("1")
+	}
+	g.offlineStrength.Label.SetText(g.config.OfflineStrength.String())
+	g.setRoot(offlineGrid)
+	etk.SetFocus(g.offlinePoints)
+	return nil
+}
+
+// selectNextStrength selects the next strength of the computer opponent.
+func (g *Game) selectNextStrength() error {
+	g.config.OfflineStrength = (g.config.OfflineStrength + 1) % client.Strength(len(client.Strengths))
+	g.offlineStrength.Label.SetText(g.config.OfflineStrength.String())
+	return nil
+}
+
+func (g *Game) selectPlayOffline() error {
+	points, err := strconv.Atoi(strings.TrimSpace(g.offlinePoints.Text()))
+	if err != nil || points < 1 {
+		points = 1
+	}
+
+	err = g.config.Save()
+	if err != nil {
+		log.Printf("error: failed to save configuration: %s", err)
+	}
+
+	g.Username = strings.TrimSpace(g.connectUsername.Text())
+	g.PlayOffline(g.config.OfflineStrength, points)
+	return nil
+}
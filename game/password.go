@@ -0,0 +1,55 @@
+package game
+
+import (
+	"strings"
+
+	"code.rocket9labs.com/tslocum/etk"
+)
+
+// passwordInput is an input which displays an asterisk in place of each
+// character entered.
+type passwordInput struct {
+	*etk.Input
+	password []rune
+}
+
+func newPasswordInput() *passwordInput {
+	i := &passwordInput{
+		Input: etk.NewInput("", "", func(text string) (handled bool) {
+			return false
+		}),
+	}
+	i.Field.SetChangedFunc(func(r rune) (accept bool) {
+		i.sync()
+		i.password = append(i.password, r)
+		i.Field.Write([]byte("*"))
+		return false
+	})
+	return i
+}
+
+// sync updates the password after characters were deleted from the field.
+// Characters are only added by the changed function, which appends them, and
+// may only be deleted from the end of the field. Any other edit leaves the
+// field out of sync with the password, so both are cleared.
+func (i *passwordInput) sync() {
+	text := i.Field.Text()
+	if len(text) > len(i.password) || strings.Trim(text, "*") != "" {
+		i.password = nil
+		i.Field.SetText("")
+		return
+	}
+	i.password = i.password[:len(text)]
+}
+
+// Text returns the password entered.
+func (i *passwordInput) Text() string {
+	i.sync()
+	return string(i.password)
+}
+
+// SetText sets the password.
+func (i *passwordInput) SetText(text string) {
+	i.password = []rune(text)
+	i.Field.SetText(strings.Repeat("*", len(i.password)))
+}
@@ -0,0 +1,424 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"code.rocket9labs.com/tslocum/bgammon"
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
+	"code.rocket9labs.com/tslocum/boxcars/game/match"
+	"code.rocket9labs.com/tslocum/etk"
+	"github.com/leonelquinteros/gotext"
+)
+
+// session is a connection to a server. Only the active session is displayed.
+// Events received by inactive sessions are queued and handled when the
+// session is activated.
+type session struct {
+	client *client.Client
+	done   chan struct{} // Closed when the session is closed.
+
+	events []interface{} // Events received while inactive.
+
+	// State of the interface, saved while the session is inactive.
+	statusText      string
+	floatStatusText string
+	gameText        string
+	statusLogged    bool
+	gameLogged      bool
+	games           []bgammon.GameListing
+	gameState       *bgammon.GameState
+	history         *match.History
+	viewBoard       bool
+
+	reconnecting  bool
+	matchID       int
+	matchPassword string
+	resumeMatch   bool
+}
+
+func (s *session) String() string {
+	name := s.client.PlayerName()
+	if name == "" {
+		return s.client.Address
+	}
+	return name + "@" + s.client.Address
+}
+
+// addSession creates a session and activates it. The session must be
+// connected by calling Connect on its client.
+func (g *Game) addSession(address string, username string, password string) *session {
+	s := &session{
+		client: g.newClient(address, username, password),
+		done:   make(chan struct{}),
+		gameState: &bgammon.GameState{
+			Game: bgammon.NewGame(),
+		},
+	}
+
+	g.sessionLock.Lock()
+	if g.session != nil {
+		g.saveSession(g.session)
+	}
+	g.setSessions(append(g.sessions, s))
+	g.loadSession(s)
+	g.sessionLock.Unlock()
+
+	g.lobby.rebuildButtonsGrid()
+
+	go g.handleEvents(s)
+	return s
+}
+
+// handleEvents handles events received by a session until it is closed.
+func (g *Game) handleEvents(s *session) {
+	for {
+		var e interface{}
+		select {
+		case e = <-s.client.Events:
+		case <-s.done:
+			return
+		}
+
+		g.sessionLock.Lock()
+		if s == g.session {
+			g.handleEvent(e)
+			g.sessionLock.Unlock()
+
+			// The session is removed after the lock is released, as
+			// removing it requires the lock.
+			if ev, ok := e.(*client.EventConnectionState); ok && ev.State == client.StateDisconnected && errors.Is(ev.Err, client.ErrRegistrationFailed) {
+				g.registrationFailed(s, ev.Err)
+			}
+			continue
+		}
+
+		switch ev := e.(type) {
+		case *bgammon.EventPing:
+			s.client.Pong(ev.Message)
+		case *bgammon.EventList:
+			// Only the latest list of matches is relevant.
+			for i := range s.events {
+				if _, ok := s.events[i].(*bgammon.EventList); ok {
+					s.events = append(s.events[:i], s.events[i+1:]...)
+					break
+				}
+			}
+			s.queueEvent(e)
+		default:
+			s.queueEvent(e)
+		}
+		g.sessionLock.Unlock()
+	}
+}
+
+// maxSessionEvents is the number of events kept while a session is inactive.
+const maxSessionEvents = 1000
+
+// queueEvent adds an event received while the session is inactive. When too
+// many events have been received, the oldest event is discarded.
+func (s *session) queueEvent(e interface{}) {
+	if len(s.events) == maxSessionEvents {
+		s.events[0] = nil
+		s.events = s.events[1:]
+	}
+	s.events = append(s.events, e)
+}
+
+// switchSession activates a session and handles any events it received while
+// inactive.
+func (g *Game) switchSession(s *session) {
+	g.sessionLock.Lock()
+	defer g.sessionLock.Unlock()
+
+	if s == g.session {
+		return
+	}
+	g.saveSession(g.session)
+	g.loadSession(s)
+
+	l("*** " + gotext.Get("Switched to %s.", s))
+
+	events := s.events
+	s.events = nil
+	for _, e := range events {
+		g.handleEvent(e)
+	}
+}
+
+// nextSession activates the session following the active session.
+func (g *Game) nextSession() {
+	g.sessionLock.Lock()
+	var next *session
+	for i, s := range g.sessions {
+		if s == g.session {
+			next = g.sessions[(i+1)%len(g.sessions)]
+			break
+		}
+	}
+	g.sessionLock.Unlock()
+
+	if next != nil {
+		g.switchSession(next)
+	}
+}
+
+// closeSession disconnects the active session and activates the previous
+// session. The last remaining session may not be closed.
+func (g *Game) closeSession() bool {
+	g.sessionLock.Lock()
+	if len(g.sessions) < 2 {
+		g.sessionLock.Unlock()
+		return false
+	}
+	closed := g.session
+	next := g.removeSession(closed)
+	g.sessionLock.Unlock()
+
+	g.switchSession(next)
+	g.lobby.rebuildButtonsGrid()
+	return true
+}
+
+// removeSession disconnects and removes a session, and returns the session
+// preceding it, or nil when no sessions remain. The session lock must be held.
+func (g *Game) removeSession(removed *session) *session {
+	var previous *session
+	for i, s := range g.sessions {
+		if s == removed {
+			sessions := append(g.sessions[:i:i], g.sessions[i+1:]...)
+			g.setSessions(sessions)
+			if len(sessions) > 0 {
+				previous = sessions[(i+len(sessions)-1)%len(sessions)]
+			}
+			break
+		}
+	}
+	removed.client.Disconnect()
+	close(removed.done)
+	return previous
+}
+
+// setSessions sets the sessions. The session lock must be held.
+func (g *Game) setSessions(sessions []*session) {
+	g.sessions = sessions
+	atomic.StoreInt32(&g.sessionCount, int32(len(sessions)))
+}
+
+// showSessions lists the sessions so that another session may be activated,
+// or the active session may be closed.
+func (g *Game) showSessions() {
+	g.sessionLock.Lock()
+	sessions := append([]*session(nil), g.sessions...)
+	active := g.session
+	g.sessionLock.Unlock()
+
+	button := func(label string, onSelected func() error) *etk.Button {
+		btn := etk.NewButton(label, onSelected)
+		btn.Label.SetFont(largeFont, fontMutex)
+		return btn
+	}
+
+	grid := etk.NewGrid()
+	grid.SetColumnPadding(int(g.Board.horizontalBorderSize / 2))
+	grid.SetRowPadding(20)
+	grid.SetColumnSizes(10, 200, -1, -1, 10)
+	grid.AddChildAt(etk.NewText(gotext.Get("Connections")), 0, 0, 4, 1)
+	rows := []int{60}
+	for i, s := range sessions {
+		label := fmt.Sprintf("%d. %s (%s)", i+1, s, s.client.State())
+		if s == active {
+			label = "* " + label
+		}
+		s := s
+		grid.AddChildAt(button(label, func() error {
+			setViewBoard(viewBoard)
+			g.switchSession(s)
+			return nil
+		}), 1, i+1, 3, 1)
+		rows = append(rows, g.scale(baseButtonHeight))
+	}
+	grid.AddChildAt(button(gotext.Get("Close connection"), func() error {
+		setViewBoard(viewBoard)
+		if !g.closeSession() {
+			l("*** " + gotext.Get("The last connection may not be closed."))
+		}
+		return nil
+	}), 2, len(sessions)+1, 1, 1)
+	grid.AddChildAt(button(gotext.Get("Cancel"), func() error {
+		setViewBoard(viewBoard)
+		return nil
+	}), 3, len(sessions)+1, 1, 1)
+	grid.SetRowSizes(append(rows, g.scale(baseButtonHeight))...)
+
+	g.hideKeyboard()
+	g.setRoot(grid)
+	scheduleFrame()
+}
+
+// connectSession adds a session and connects it.
+func (g *Game) connectSession(address string, username string, password string) {
+	s := g.addSession(address, username, password)
+	l("*** " + gotext.Get("Connecting to %s...", address))
+	go s.client.Connect()
+}
+
+// showConnectSession prompts for the password used to add a session.
+func (g *Game) showConnectSession(address string, username string) {
+	g.sessionAddress = address
+	g.sessionUsername = username
+	g.sessionPassword.SetText("")
+	g.sessionInfo.SetText(gotext.Get("Enter the password of %s on %s, or leave it empty to log in as a guest.", username, address))
+	g.hideKeyboard()
+	g.setRoot(sessionGrid)
+	etk.SetFocus(g.sessionPassword.Input)
+}
+
+func (g *Game) selectConnectSession() error {
+	password := g.sessionPassword.Text()
+	g.sessionPassword.SetText("")
+	setViewBoard(viewBoard)
+	g.connectSession(g.sessionAddress, g.sessionUsername, password)
+	return nil
+}
+
+func (g *Game) cancelConnectSession() error {
+	g.sessionPassword.SetText("")
+	setViewBoard(viewBoard)
+	return nil
+}
+
+// saveSession saves the state of the interface to a session.
+func (g *Game) saveSession(s *session) {
+	s.statusText = statusBuffer.Text()
+	s.floatStatusText = floatStatusBuffer.Text()
+	s.gameText = gameBuffer.Text()
+	s.statusLogged = statusLogged
+	s.gameLogged = gameLogged
+	s.games = g.lobby.games
+	s.viewBoard = viewBoard
+
+	g.Board.Lock()
+	s.gameState = g.Board.gameState
+	s.history = g.Board.history
+	g.Board.Unlock()
+
+	s.reconnecting = g.reconnecting
+	s.matchID = g.matchID
+	s.matchPassword = g.matchPassword
+	s.resumeMatch = g.resumeMatch
+}
+
+// loadSession restores the state of the interface from a session and makes
+// it the active session.
+func (g *Game) loadSession(s *session) {
+	g.session = s
+	g.Client = s.client
+	g.lobby.c = s.client
+	g.Board.Client = s.client
+
+	statusBuffer.SetText(s.statusText)
+	floatStatusBuffer.SetText(s.floatStatusText)
+	gameBuffer.SetText(s.gameText)
+	statusLogged = s.statusLogged
+	gameLogged = s.gameLogged
+
+	g.lobby.games = s.games
+	g.lobby.loaded = s.games != nil
+	g.lobby.selected = 0
+	g.lobby.offset = 0
+	g.lobby.bufferDirty = true
+
+	g.Board.Lock()
+	g.Board.gameState = s.gameState
+	g.Board.history = s.history
+	g.Board.viewEntry = nil
+	g.Board.dragging = nil
+	g.Board.processState()
+	g.Board.updateHistoryList()
+	g.Board.Unlock()
+
+	g.reconnecting = s.reconnecting
+	g.matchID = s.matchID
+	g.matchPassword = s.matchPassword
+	g.resumeMatch = s.resumeMatch
+
+	setViewBoard(s.viewBoard)
+	scheduleFrame()
+}
+
+// handleSessionCommand handles commands which manage sessions. It returns
+// whether the command was handled.
+func (g *Game) handleSessionCommand(command string) bool {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return false
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "connect":
+		if len(fields) < 2 || len(fields) > 3 {
+			// Passwords are never accepted as chat text, which is shown on
+			// screen. They are entered in a masked input instead.
+			l("*** " + gotext.Get("Usage: %s", "/connect <address> [username]"))
+			return true
+		} else if len(fields) == 2 {
+			g.connectSession(fields[1], "", "")
+			return true
+		}
+		g.showConnectSession(fields[1], fields[2])
+	case "switch":
+		if len(fields) < 2 {
+			g.nextSession()
+			return true
+		}
+		n, err := strconv.Atoi(fields[1])
+		g.sessionLock.Lock()
+		if err != nil || n < 1 || n > len(g.sessions) {
+			g.sessionLock.Unlock()
+			l("*** " + gotext.Get("Unknown connection: %s", fields[1]))
+			return true
+		}
+		s := g.sessions[n-1]
+		g.sessionLock.Unlock()
+		g.switchSession(s)
+	case "sessions", "connections":
+		g.sessionLock.Lock()
+		var lines []string
+		for i, s := range g.sessions {
+			active := " "
+			if s == g.session {
+				active = "*"
+			}
+			lines = append(lines, fmt.Sprintf("%s %d. %s (%s)", active, i+1, s, s.client.State()))
+		}
+		g.sessionLock.Unlock()
+		l("*** " + gotext.Get("Connections:"))
+		for _, line := range lines {
+			l("*** " + line)
+		}
+	case "close":
+		if !g.closeSession() {
+			l("*** " + gotext.Get("The last connection may not be closed."))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// showRawEvents prints the raw event log of the active session.
+func (g *Game) showRawEvents() {
+	events := g.Client.RawEvents()
+	if len(events) == 0 {
+		l("*** " + gotext.Get("No unsupported events have been received."))
+		return
+	}
+	l("*** " + gotext.Get("Unsupported events:"))
+	for _, ev := range events {
+		l(fmt.Sprintf("*** %s %s (%s)", ev.Time.Format("15:04:05"), ev.Data, ev.Err))
+	}
+}
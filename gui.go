@@ -0,0 +1,103 @@
+//go:build !nogui
+
+package main
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"code.rocket9labs.com/tslocum/boxcars/game"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	screenWidth  = 1024
+	screenHeight = 768
+)
+
+// runGUI runs the graphical interface.
+func runGUI(o *options) {
+	ebiten.SetWindowTitle("bgammon.org - Free Online Backgammon")
+	ebiten.SetWindowSize(screenWidth, screenHeight)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetVsyncEnabled(true)
+	ebiten.SetScreenClearedEveryFrame(false)
+	ebiten.SetTPS(144)
+	ebiten.SetRunnableOnUnfocused(true)
+	ebiten.SetWindowClosingHandled(true)
+
+	g := newGame(o)
+
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			g.Client.Send(scanner.Text())
+		}
+	}()
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc,
+		syscall.SIGINT,
+		syscall.SIGTERM)
+	go func() {
+		<-sigc
+
+		g.Exit()
+	}()
+
+	if err := ebiten.RunGame(g); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newGame returns a game configured using the specified options.
+func newGame(o *options) *game.Game {
+	game.LoadLocale(o.locale)
+
+	g := game.NewGame()
+	if o.profile != nil {
+		err := g.UseProfile(o.profile.Name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		g.AutoConnect = true
+	}
+	if o.usernameSet {
+		g.Username = o.username
+	}
+	if o.passwordSet {
+		g.Password = o.password
+	}
+	if o.addressSet || g.ServerAddress == "" {
+		g.ServerAddress = o.serverAddress
+	}
+	g.Watch = o.watch
+	g.TV = o.tv
+	g.TLS = o.tls
+	g.Proxy = o.proxy
+	g.Record = o.record
+	g.MatchDir = o.matchDir
+	g.Transport = o.replay
+
+	if o.open != "" {
+		buf, err := os.ReadFile(o.open)
+		if err != nil {
+			log.Fatalf("failed to open match file: %s", err)
+		}
+		err = g.OpenMatch(filepath.Base(o.open), buf)
+		if err != nil {
+			log.Fatalf("failed to open match file: %s", err)
+		}
+	}
+
+	if o.touch {
+		g.EnableTouchInput()
+	}
+
+	serveDebug(o, g.ServeMetrics)
+	return g
+}
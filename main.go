@@ -3,51 +3,156 @@ package main
 //go:generate xgotext -no-locations -default boxcars -in . -out game/locales
 
 import (
-	"bufio"
+	"fmt"
 	"log"
+	"net/http"
 	_ "net/http/pprof"
+	"net/url"
 	"os"
-	"os/signal"
-	"syscall"
 
-	"github.com/hajimehoshi/ebiten/v2"
+	"code.rocket9labs.com/tslocum/boxcars/game/client"
+	"code.rocket9labs.com/tslocum/boxcars/game/locales"
+	"golang.org/x/text/language"
 )
 
-const (
-	screenWidth  = 1024
-	screenHeight = 768
-)
+// options are the options specified via command-line flags.
+type options struct {
+	username      string
+	usernameSet   bool
+	password      string
+	passwordSet   bool
+	serverAddress string
+	addressSet    bool // The server address was specified.
+	locale        *language.Tag
+	profile       *client.Profile
+	watch         bool
+	tv            bool
+	debug         int
+	touch         bool
+	tls           *client.TLSOptions
+	proxy         *url.URL
+	record        *client.Recorder
+	matchDir      string
+	replay        client.Transport
+	open          string
+	headless      bool
+
+	bot         bool
+	botJoin     int
+	botPoints   int
+	botName     string
+	botMatches  int
+	botStrategy client.Strategy
+}
 
 func main() {
-	ebiten.SetWindowTitle("bgammon.org - Free Online Backgammon")
-	ebiten.SetWindowSize(screenWidth, screenHeight)
-	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
-	ebiten.SetVsyncEnabled(true)
-	ebiten.SetScreenClearedEveryFrame(false)
-	ebiten.SetTPS(144)
-	ebiten.SetRunnableOnUnfocused(true)
-	ebiten.SetWindowClosingHandled(true)
+	o := parseFlags()
 
-	g := parseFlags()
+	if o.bot {
+		err := runBot(o)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if o.headless {
+		err := runHeadless(o)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	go func() {
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() {
-			g.Client.Out <- append(scanner.Bytes(), '\n')
+	runGUI(o)
+}
+
+// runHeadless runs in a terminal. The graphical interface is not started, so
+// no display is required.
+func runHeadless(o *options) error {
+	locales.Load(o.locale)
+
+	c := o.newClient()
+	serveDebug(o, clientMetrics(c))
+	return client.RunHeadless(c, client.HeadlessOptions{Watch: o.watch, TV: o.tv}, os.Stdin, os.Stdout)
+}
+
+// runBot plays matches automatically. The graphical interface is not
+// started, so no display is required.
+func runBot(o *options) error {
+	options := client.BotOptions{
+		Join:    o.botJoin,
+		Matches: o.botMatches,
+	}
+	if o.botJoin == 0 {
+		name := o.botName
+		if name == "" && o.username != "" {
+			name = fmt.Sprintf("%s's match", o.username)
+		} else if name == "" {
+			name = "Bot match"
 		}
-	}()
+		options.Create = &client.MatchOptions{
+			Name:   name,
+			Points: o.botPoints,
+		}
+	}
 
-	sigc := make(chan os.Signal, 1)
-	signal.Notify(sigc,
-		syscall.SIGINT,
-		syscall.SIGTERM)
-	go func() {
-		<-sigc
+	c := o.newClient()
+	serveDebug(o, clientMetrics(c))
+	return client.NewBot(c, o.botStrategy, options).Run()
+}
 
-		g.Exit()
+// newClient returns a client configured using the specified options.
+func (o *options) newClient() *client.Client {
+	if p := o.profile; p != nil {
+		if !o.usernameSet {
+			o.username = p.Username
+		}
+		if !o.passwordSet {
+			o.password = p.GetPassword()
+		}
+		if !o.addressSet && p.Server != "" {
+			o.serverAddress = p.Server
+		}
+	}
+	address := o.serverAddress
+	if address == "" {
+		address = client.DefaultServerAddress
+	}
+	c := client.NewClient(address, o.username, o.password)
+	if o.replay != nil {
+		// Recordings may only be replayed once, so the same transport is
+		// used for every connection attempt.
+		t := o.replay
+		c.NewTransport = func() client.Transport {
+			return t
+		}
+	}
+	c.TLS = o.tls
+	c.Proxy = o.proxy
+	if o.record != nil {
+		c.Record(o.record)
+	}
+	return c
+}
+
+// serveDebug serves pprof and the provided metrics handler on the port
+// specified via the debug flag.
+func serveDebug(o *options, metrics http.HandlerFunc) {
+	if o.debug <= 0 {
+		return
+	}
+	http.HandleFunc("/metrics", metrics)
+	go func() {
+		log.Fatal(http.ListenAndServe(fmt.Sprintf("localhost:%d", o.debug), nil))
 	}()
+}
 
-	if err := ebiten.RunGame(g); err != nil {
-		log.Fatal(err)
+// clientMetrics returns a handler which serves the metrics of a client in the
+// Prometheus text format.
+func clientMetrics(c *client.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m := &client.MetricsWriter{}
+		m.WriteClients([]*client.Client{c})
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(m.Bytes())
 	}
 }
@@ -0,0 +1,10 @@
+//go:build nogui
+
+package main
+
+import "log"
+
+// runGUI is unavailable when built without the graphical interface.
+func runGUI(o *options) {
+	log.Fatal("built without the graphical interface: run with -headless or -bot")
+}